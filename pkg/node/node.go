@@ -306,6 +306,7 @@ func (n *Node) Shutdown(ctx context.Context) error {
 	}
 
 	if n.tracer != nil {
+		// A failed flush, e.g. of an unreachable collector, doesn't fail the shutdown
 		if err := n.tracer.ForceFlush(ctx); err != nil {
 			n.log.Error("could not force flush tracer", zap.Error(err))
 		}
@@ -474,7 +475,7 @@ func (n *Node) startServer(nodeConfig *WunderNodeConfig) error {
 			Batcher:      "otlphttp",
 			OtlpHttpPath: "/v1/traces",
 			BatchTimeout: n.options.traceBatchTimeout,
-			Sampler:      sampler,
+			SamplerRatio: &sampler,
 			OtlpHeaders: map[string]string{
 				"Authorization": fmt.Sprintf("Bearer %s", nodeConfig.Api.Options.OpenTelemetry.AuthToken),
 			},
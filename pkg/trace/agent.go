@@ -3,36 +3,269 @@ package trace
 import (
 	"context"
 	"fmt"
+	"net/http"
 	"net/url"
+	"sort"
+	"strings"
+	"sync"
+	"time"
 
+	"github.com/hashicorp/go-multierror"
 	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracegrpc"
 	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
-	"go.opentelemetry.io/otel/sdk/resource"
+	"go.opentelemetry.io/otel/exporters/stdout/stdouttrace"
+	"go.opentelemetry.io/otel/exporters/zipkin"
 	sdktrace "go.opentelemetry.io/otel/sdk/trace"
-	semconv "go.opentelemetry.io/otel/semconv/v1.4.0"
+	"go.opentelemetry.io/otel/trace"
 	"go.uber.org/zap"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials"
+	// Registers the gzip compressor used by the OTLP gRPC exporter
+	_ "google.golang.org/grpc/encoding/gzip"
 )
 
 const (
 	kindOtlpHttp = "otlphttp"
+	kindOtlpGrpc = "otlpgrpc"
+	kindJaeger   = "jaeger" // exported with OTLP HTTP to the OTLP receiver of Jaeger
+	kindZipkin   = "zipkin"
+	kindStdout   = "stdout"
+	kindFile     = "file"
 )
 
+const (
+	compressionNone = "none"
+	compressionGzip = "gzip"
+)
+
+// previousShutdownTimeout bounds the shutdown of the provider
+// replaced by a new agent, so it can't block the startup.
+const previousShutdownTimeout = 5 * time.Second
+
 var (
+	// tp is the provider installed globally by the last StartAgent call
 	tp *sdktrace.TracerProvider
+	// serviceName is the Config.Name of tp
+	serviceName string
+	// exporting reports whether tp exports the spans
+	exporting bool
+	tpMu      sync.Mutex
 )
 
-// StartAgent starts an opentelemetry agent.
+// StartAgent starts an opentelemetry agent. If the provider is installed globally,
+// the provider installed by a previous call is shut down.
 func StartAgent(log *zap.Logger, c Config) (*sdktrace.TracerProvider, error) {
-	return startAgent(log, c)
+	return StartAgentWithContext(context.Background(), log, c)
+}
+
+// StartAgentWithContext starts an opentelemetry agent like StartAgent.
+// The context bounds the creation of the exporters, e.g. to abort
+// the startup if the collector can't be reached in time.
+func StartAgentWithContext(ctx context.Context, log *zap.Logger, c Config) (*sdktrace.TracerProvider, error) {
+	return startAgent(ctx, log, c)
+}
+
+// StartAgentContext starts an opentelemetry agent like StartAgentWithContext.
+//
+// Deprecated: Use StartAgentWithContext.
+func StartAgentContext(ctx context.Context, log *zap.Logger, c Config) (*sdktrace.TracerProvider, error) {
+	return StartAgentWithContext(ctx, log, c)
+}
+
+// StartAgentWithShutdown starts an opentelemetry agent like StartAgent and
+// additionally returns a function which flushes and shuts down the provider.
+// The shutdown function can be called multiple times, only the first call
+// shuts down the provider and subsequent calls return its result.
+func StartAgentWithShutdown(log *zap.Logger, c Config) (*sdktrace.TracerProvider, func(context.Context) error, error) {
+	provider, err := startAgent(context.Background(), log, c)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	var (
+		once        sync.Once
+		shutdownErr error
+	)
+	shutdown := func(ctx context.Context) error {
+		once.Do(func() {
+			shutdownErr = ShutdownAgent(ctx, provider)
+		})
+		return shutdownErr
+	}
+	return provider, shutdown, nil
+}
+
+// ShutdownAgent flushes all buffered spans and shuts down the given provider.
+// Callers should wire it into their shutdown sequence with a bounded context,
+// because the flush of the batcher blocks until the context is done.
+// It's a no-op for a nil provider.
+func ShutdownAgent(ctx context.Context, tp *sdktrace.TracerProvider) error {
+	if tp == nil {
+		return nil
+	}
+
+	ratioSamplers.Delete(tp)
+
+	var err error
+	if flushErr := tp.ForceFlush(ctx); flushErr != nil {
+		err = multierror.Append(err, fmt.Errorf("could not force flush tracer: %w", flushErr))
+	}
+	// Shutdown even if the flush failed to release the exporter resources
+	if shutdownErr := tp.Shutdown(ctx); shutdownErr != nil {
+		err = multierror.Append(err, fmt.Errorf("could not shutdown tracer: %w", shutdownErr))
+	}
+	return err
+}
+
+// Provider returns the provider installed globally by the last StartAgent
+// call or nil if no agent was started.
+func Provider() *sdktrace.TracerProvider {
+	tpMu.Lock()
+	defer tpMu.Unlock()
+	return tp
+}
+
+// Shutdown flushes and shuts down the provider installed globally by the
+// last StartAgent call. It's a no-op if no agent was started.
+func Shutdown(ctx context.Context) error {
+	return ShutdownAgent(ctx, Provider())
+}
+
+// IsEnabled reports whether the provider installed globally by the last
+// StartAgent call exports the spans. It's false if no agent was started,
+// the agent is disabled or no exporter is configured.
+func IsEnabled() bool {
+	tpMu.Lock()
+	defer tpMu.Unlock()
+	return exporting
+}
+
+// ForceFlush exports all buffered spans of the provider installed globally
+// by the last StartAgent call, e.g. before the process is terminated.
+// It blocks until the spans are exported or ctx is done. It's a no-op if
+// no agent was started.
+func ForceFlush(ctx context.Context) error {
+	tp := Provider()
+	if tp == nil {
+		return nil
+	}
+	return tp.ForceFlush(ctx)
+}
+
+// parseEndpoint parses the configured endpoint and reports whether
+// the connection to it should be made without TLS. Unless Config.Insecure
+// is set, only https endpoints use TLS. Endpoints without scheme, e.g.
+// localhost:4318, default to http or to https if TLS is configured.
+func parseEndpoint(c Config) (*url.URL, bool, error) {
+	u, err := endpointURL(c.Endpoint, tlsConfigured(c))
+	if err != nil {
+		return nil, false, fmt.Errorf("%w: %w", ErrInvalidEndpoint, err)
+	}
+	if c.Insecure != nil {
+		return u, *c.Insecure, nil
+	}
+	return u, u.Scheme != "https", nil
+}
+
+// endpointURL parses endpoint, the scheme defaults to https if tls
+// is set and to http otherwise.
+func endpointURL(endpoint string, tls bool) (*url.URL, error) {
+	if endpoint != "" && !strings.Contains(endpoint, "://") {
+		scheme := "http"
+		if tls {
+			scheme = "https"
+		}
+		endpoint = scheme + "://" + endpoint
+	}
+	return url.Parse(endpoint)
+}
+
+// tlsConfigured reports whether TLS is enforced or client certificates are configured.
+func tlsConfigured(c Config) bool {
+	if c.Insecure != nil {
+		return !*c.Insecure
+	}
+	return len(c.TLSCertFile) > 0 || len(c.TLSKeyFile) > 0 || len(c.TLSCACertFile) > 0 || c.TLSInsecureSkipVerify
+}
+
+// defaultOtlpHttpTimeout is the default timeout of the otlptracehttp exporter
+const defaultOtlpHttpTimeout = 10 * time.Second
+
+// otlpHttpTimeout returns the timeout of a single OTLP HTTP request.
+func otlpHttpTimeout(c Config) time.Duration {
+	switch {
+	case c.HttpTimeout > 0:
+		return c.HttpTimeout
+	case c.ExportTimeout > 0:
+		return c.ExportTimeout
+	default:
+		return defaultOtlpHttpTimeout
+	}
+}
+
+// parseProxyURL parses Config.ProxyURL.
+func parseProxyURL(s string) (*url.URL, error) {
+	u, err := url.Parse(s)
+	if err != nil {
+		return nil, fmt.Errorf("invalid proxy url: %w", err)
+	}
+	if u.Scheme == "" || u.Host == "" {
+		return nil, fmt.Errorf("invalid proxy url %q: missing scheme or host", s)
+	}
+	return u, nil
+}
+
+// Same as the defaults of the OTLP exporters
+const (
+	defaultRetryInitialInterval = 5 * time.Second
+	defaultRetryMaxInterval     = 30 * time.Second
+	defaultRetryMaxElapsedTime  = time.Minute
+)
+
+func otlpHttpRetryConfig(c *RetryConfig) otlptracehttp.RetryConfig {
+	if !c.Enabled {
+		return otlptracehttp.RetryConfig{Enabled: false}
+	}
+	rc := otlptracehttp.RetryConfig{
+		Enabled:         true,
+		InitialInterval: defaultRetryInitialInterval,
+		MaxInterval:     defaultRetryMaxInterval,
+		MaxElapsedTime:  defaultRetryMaxElapsedTime,
+	}
+	if c.InitialInterval > 0 {
+		rc.InitialInterval = c.InitialInterval
+	}
+	if c.MaxInterval > 0 {
+		rc.MaxInterval = c.MaxInterval
+	}
+	if c.MaxElapsedTime > 0 {
+		rc.MaxElapsedTime = c.MaxElapsedTime
+	}
+	return rc
 }
 
-func createExporter(c Config) (sdktrace.SpanExporter, error) {
-	// Just support OTLP for now. Jaeger has native OTLP support.
+func createExporter(ctx context.Context, c Config) (sdktrace.SpanExporter, error) {
 	switch c.Batcher {
 	case kindOtlpHttp:
-		u, err := url.Parse(c.Endpoint)
+		u, insecure, err := parseEndpoint(c)
+		if err != nil {
+			return nil, err
+		}
+		// A full URL is exported to as is
+		if len(c.OtlpHttpPath) == 0 && u.Path != "" && u.Path != "/" {
+			c.OtlpHttpPath = u.Path
+		}
+
+		// The otlptracehttp exporter doesn't allow to set the proxy or per request headers
+		if c.HTTPClient != nil || c.HeaderProvider != nil || len(c.ProxyURL) > 0 {
+			return newOtlpHttpExporter(ctx, c, u, insecure)
+		}
+
+		tlsConfig, err := createTLSConfig(c)
 		if err != nil {
-			return nil, fmt.Errorf("invalid OpenTelemetry endpoint: %w", err)
+			return nil, err
 		}
 
 		opts := []otlptracehttp.Option{
@@ -40,7 +273,9 @@ func createExporter(c Config) (sdktrace.SpanExporter, error) {
 			otlptracehttp.WithEndpoint(u.Host),
 		}
 
-		if u.Scheme != "https" {
+		if tlsConfig != nil {
+			opts = append(opts, otlptracehttp.WithTLSClientConfig(tlsConfig))
+		} else if insecure {
 			opts = append(opts, otlptracehttp.WithInsecure())
 		}
 
@@ -50,50 +285,467 @@ func createExporter(c Config) (sdktrace.SpanExporter, error) {
 		if len(c.OtlpHttpPath) > 0 {
 			opts = append(opts, otlptracehttp.WithURLPath(c.OtlpHttpPath))
 		}
+		switch c.Compression {
+		case "", compressionNone:
+		case compressionGzip:
+			opts = append(opts, otlptracehttp.WithCompression(otlptracehttp.GzipCompression))
+		default:
+			return nil, fmt.Errorf("unknown compression: %s", c.Compression)
+		}
+		if c.HttpTimeout > 0 || c.ExportTimeout > 0 {
+			opts = append(opts, otlptracehttp.WithTimeout(otlpHttpTimeout(c)))
+		}
+		if c.RetryConfig != nil {
+			opts = append(opts, otlptracehttp.WithRetry(otlpHttpRetryConfig(c.RetryConfig)))
+		}
 		return otlptracehttp.New(
-			context.Background(),
+			ctx,
+			opts...,
+		)
+	case kindOtlpGrpc:
+		u, insecure, err := parseEndpoint(c)
+		if err != nil {
+			return nil, err
+		}
+
+		tlsConfig, err := createTLSConfig(c)
+		if err != nil {
+			return nil, err
+		}
+
+		opts := []otlptracegrpc.Option{
+			// Includes host and port
+			otlptracegrpc.WithEndpoint(u.Host),
+		}
+
+		if tlsConfig != nil {
+			opts = append(opts, otlptracegrpc.WithTLSCredentials(credentials.NewTLS(tlsConfig)))
+		} else if insecure {
+			opts = append(opts, otlptracegrpc.WithInsecure())
+		}
+
+		if len(c.OtlpHeaders) > 0 {
+			opts = append(opts, otlptracegrpc.WithHeaders(c.OtlpHeaders))
+		}
+		if c.HeaderProvider != nil {
+			opts = append(opts, otlptracegrpc.WithDialOption(
+				grpc.WithPerRPCCredentials(headerCredentials{provider: c.HeaderProvider}),
+			))
+		}
+
+		switch c.Compression {
+		case "", compressionNone:
+		case compressionGzip:
+			opts = append(opts, otlptracegrpc.WithCompressor(compressionGzip))
+		default:
+			return nil, fmt.Errorf("unknown compression: %s", c.Compression)
+		}
+
+		if c.DialTimeout > 0 {
+			var cancel context.CancelFunc
+			ctx, cancel = context.WithTimeout(ctx, c.DialTimeout)
+			defer cancel()
+			// Block until the connection is established, so an unreachable
+			// collector fails the startup after DialTimeout.
+			opts = append(opts, otlptracegrpc.WithDialOption(grpc.WithBlock()))
+		}
+		return otlptracegrpc.New(
+			ctx,
 			opts...,
 		)
+	case kindJaeger:
+		u, _, err := parseEndpoint(c)
+		if err != nil {
+			return nil, err
+		}
+		if err := validateJaegerEndpoint(u); err != nil {
+			return nil, err
+		}
+		// Jaeger receives OTLP natively, the Jaeger exporter is deprecated
+		c.Batcher = kindOtlpHttp
+		return createExporter(ctx, c)
+	case kindZipkin:
+		u, _, err := parseEndpoint(c)
+		if err != nil {
+			return nil, err
+		}
+		if err := validateZipkinEndpoint(u); err != nil {
+			return nil, err
+		}
+
+		client, err := exporterHTTPClient(c)
+		if err != nil {
+			return nil, err
+		}
+		// The endpoint is the full URL e.g. http://localhost:9411/api/v2/spans
+		return zipkin.New(u.String(), zipkin.WithClient(client))
+	case kindStdout:
+		var opts []stdouttrace.Option
+		if c.PrettyPrint {
+			opts = append(opts, stdouttrace.WithPrettyPrint())
+		}
+		return stdouttrace.New(opts...)
+	case kindFile:
+		return newFileExporter(c)
 	default:
-		return nil, fmt.Errorf("unknown exporter: %s", c.Batcher)
+		return nil, fmt.Errorf("%w: %s", ErrUnknownExporter, c.Batcher)
+	}
+}
+
+// newOtlpHttpExporter creates an OTLP HTTP exporter sending the spans with Config.HTTPClient
+// or the client of exporterHTTPClient.
+// With Config.HeaderProvider, the client adds its headers to every request.
+func newOtlpHttpExporter(ctx context.Context, c Config, u *url.URL, insecure bool) (sdktrace.SpanExporter, error) {
+	target := url.URL{
+		Scheme: "https",
+		Host:   u.Host,
+		Path:   defaultOtlpHttpPath,
+	}
+	if insecure {
+		target.Scheme = "http"
+	}
+	if len(c.OtlpHttpPath) > 0 {
+		target.Path = c.OtlpHttpPath
+	}
+
+	var compress bool
+	switch c.Compression {
+	case "", compressionNone:
+	case compressionGzip:
+		compress = true
+	default:
+		return nil, fmt.Errorf("unknown compression: %s", c.Compression)
+	}
+
+	client := c.HTTPClient
+	if client == nil || c.HeaderProvider != nil {
+		var err error
+		if client, err = exporterHTTPClient(c); err != nil {
+			return nil, err
+		}
+		if c.HTTPClient == nil {
+			client.Timeout = otlpHttpTimeout(c)
+		}
+	}
+
+	// Retry like the otlptracehttp exporter, which retries by default
+	retry := c.RetryConfig
+	if retry == nil {
+		retry = &RetryConfig{Enabled: true}
+	}
+
+	return otlptrace.New(ctx, &otlpHttpClient{
+		client:   client,
+		url:      target.String(),
+		headers:  c.OtlpHeaders,
+		compress: compress,
+		retry:    otlpHttpRetryConfig(retry),
+	})
+}
+
+// exporterHTTPClient returns Config.HTTPClient or a client using the TLS options and Config.ProxyURL.
+// The client sets Config.OtlpHeaders and the headers of Config.HeaderProvider
+// on every request.
+func exporterHTTPClient(c Config) (*http.Client, error) {
+	var client http.Client
+	if c.HTTPClient != nil {
+		client = *c.HTTPClient
+	} else {
+		tlsConfig, err := createTLSConfig(c)
+		if err != nil {
+			return nil, err
+		}
+		transport := http.DefaultTransport.(*http.Transport).Clone()
+		if tlsConfig != nil {
+			transport.TLSClientConfig = tlsConfig
+		}
+		if len(c.ProxyURL) > 0 {
+			proxy, err := parseProxyURL(c.ProxyURL)
+			if err != nil {
+				return nil, err
+			}
+			transport.Proxy = http.ProxyURL(proxy)
+		}
+		client.Transport = transport
+	}
+
+	if len(c.OtlpHeaders) > 0 || c.HeaderProvider != nil {
+		rt := client.Transport
+		if rt == nil {
+			rt = http.DefaultTransport
+		}
+		client.Transport = &headerTransport{
+			rt:       rt,
+			headers:  c.OtlpHeaders,
+			provider: c.HeaderProvider,
+		}
+	}
+	return &client, nil
+}
+
+// exporterConfigs returns a config for every exporter to create.
+// The exporter configured directly on c comes first, followed by Config.Exporters.
+func exporterConfigs(c Config) []Config {
+	configs := make([]Config, 0, len(c.Exporters)+1)
+	if len(c.Endpoint) > 0 || !needsEndpoint(c.Batcher) {
+		configs = append(configs, c)
+	}
+	for _, e := range c.Exporters {
+		ec := c
+		ec.Batcher = e.Batcher
+		ec.Endpoint = e.Endpoint
+		ec.OtlpHeaders = e.OtlpHeaders
+		ec.OtlpHttpPath = e.OtlpHttpPath
+		if e.SampleRatio > 0 {
+			ec.Sampler = e.SampleRatio
+			ec.SamplerRatio = nil
+		}
+		configs = append(configs, ec)
+	}
+	return configs
+}
+
+// needsEndpoint reports whether the batcher exports to Config.Endpoint.
+// The stdout and file exporters write the spans locally.
+func needsEndpoint(batcher string) bool {
+	return batcher != kindStdout && batcher != kindFile
+}
+
+func batchSpanProcessorOptions(c Config) []sdktrace.BatchSpanProcessorOption {
+	opts := []sdktrace.BatchSpanProcessorOption{
+		sdktrace.WithMaxExportBatchSize(512),
+		sdktrace.WithMaxQueueSize(2048),
+	}
+
+	// A zero timeout would export every span on its own
+	if c.BatchTimeout > 0 {
+		opts = append(opts, sdktrace.WithBatchTimeout(c.BatchTimeout))
+	}
+	if c.MaxExportBatchSize > 0 {
+		opts = append(opts, sdktrace.WithMaxExportBatchSize(c.MaxExportBatchSize))
+	}
+	if c.MaxQueueSize > 0 {
+		opts = append(opts, sdktrace.WithMaxQueueSize(c.MaxQueueSize))
+	}
+	if c.ExportTimeout > 0 {
+		opts = append(opts, sdktrace.WithExportTimeout(c.ExportTimeout))
 	}
+	return opts
 }
 
-func startAgent(log *zap.Logger, c Config) (*sdktrace.TracerProvider, error) {
+// exporterProcessor creates the exporter of ec and the span processor passing
+// the spans to it. The settings shared by all exporters are taken from c.
+func exporterProcessor(ctx context.Context, c, ec Config) (sdktrace.SpanProcessor, error) {
+	exp, err := createExporter(ctx, ec)
+	if err != nil {
+		return nil, err
+	}
+	if c.ExporterMetrics != nil {
+		exp = &meteredExporter{SpanExporter: exp, metrics: c.ExporterMetrics}
+	}
+	if ec.Batcher == kindStdout || c.SyncExport {
+		// Export spans as soon as they end, this is meant for local development and tests only.
+		return sdktrace.NewSimpleSpanProcessor(exp), nil
+	}
+	// Always be sure to batch in production.
+	return sdktrace.NewBatchSpanProcessor(exp, batchSpanProcessorOptions(ec)...), nil
+}
+
+func startAgent(ctx context.Context, log *zap.Logger, c Config) (*sdktrace.TracerProvider, error) {
+	if c.Disabled {
+		// The provider never samples, so no spans are recorded
+		provider := sdktrace.NewTracerProvider(sdktrace.WithSampler(sdktrace.NeverSample()))
+		installProvider(ctx, log, c, provider, trace.NewNoopTracerProvider())
+		return provider, nil
+	}
+
+	if err := c.Validate(); err != nil {
+		log.Error("invalid trace config", zap.Error(err))
+		return nil, err
+	}
+
+	// Config.OtlpHeadersRaw only applies to the exporter configured directly on c
+	headers, err := otlpHeaders(c)
+	if err != nil {
+		log.Error("invalid OpenTelemetry headers", zap.Error(err))
+		return nil, err
+	}
+	c.OtlpHeaders = headers
+
+	if c.ProbeOnStart {
+		if err := ProbeEndpoint(ctx, c); err != nil {
+			log.Error("OpenTelemetry endpoint unreachable", zap.Error(err))
+			return nil, err
+		}
+	}
+
+	propagator, err := createPropagator(c.Propagators)
+	if err != nil {
+		log.Error("create propagator error", zap.Error(err))
+		return nil, err
+	}
+
+	if r := c.SamplerRatio; r != nil && (*r < 0 || *r > 1) {
+		log.Warn("sampler ratio out of range, clamping to [0, 1]", zap.Float64("sampler_ratio", *r))
+	} else if r == nil && (c.Sampler < 0 || c.Sampler > 1) {
+		log.Warn("sampler out of range, clamping to [0, 1]", zap.Float64("sampler", c.Sampler))
+	}
+	configs := exporterConfigs(c)
+	headRatio := headSampleRatio(configs)
+	sc := c
+	if hasRatioSampler(c) && headRatio > configRatio(c) {
+		// Sample enough traces for the exporter with the highest ExporterConfig.SampleRatio
+		sc.SamplerRatio = &headRatio
+	}
+	sampler, state, err := createSampler(sc)
+	if err != nil {
+		log.Error("create sampler error", zap.Error(err))
+		return nil, err
+	}
+	if len(c.ErrorExporterEndpoint) > 0 {
+		// The error spans of unsampled traces must be recorded to be rescued
+		sampler = recordUnsampledSampler{delegate: sampler}
+	}
+
 	opts := []sdktrace.TracerProviderOption{
-		// Set the sampling rate based on the parent span to 100%
-		sdktrace.WithSampler(
-			sdktrace.ParentBased(
-				sdktrace.TraceIDRatioBased(c.Sampler),
-				// By default of the parent span is sampled, the child span will be sampled.
-			),
-		),
+		sdktrace.WithSampler(sampler),
 		// Record information about this application in a Resource.
-		sdktrace.WithResource(resource.NewSchemaless(semconv.ServiceNameKey.String(c.Name))),
+		sdktrace.WithResource(createResource(log, c)),
+		sdktrace.WithRawSpanLimits(c.SpanLimits.sdkSpanLimits()),
+	}
+	if c.IDGenerator != nil {
+		opts = append(opts, sdktrace.WithIDGenerator(c.IDGenerator))
 	}
 
-	if len(c.Endpoint) > 0 {
-		exp, err := createExporter(c)
+	// Registered first, so the attributes are set before other processors see the span
+	if len(c.DefaultSpanAttributes) > 0 {
+		opts = append(opts, sdktrace.WithSpanProcessor(newDefaultAttributesProcessor(c.DefaultSpanAttributes)))
+	}
+
+	for _, sp := range c.SpanProcessors {
+		opts = append(opts, sdktrace.WithSpanProcessor(sp))
+	}
+	cleanup := &samplerStateProcessor{state: state}
+	if state.ratio != nil || state.remote != nil {
+		opts = append(opts, sdktrace.WithSpanProcessor(cleanup))
+	}
+
+	// Every exporter gets its own span processor, shutting down
+	// the provider flushes all of them.
+	var exporters []sdktrace.SpanProcessor
+	for _, ec := range configs {
+		sp, err := exporterProcessor(ctx, c, ec)
+		if err != nil {
+			log.Error("create exporter error", zap.Error(err), zap.String("batcher", ec.Batcher))
+			return nil, err
+		}
+		if ratio, ok := exporterSampleRatio(c, ec, headRatio); ok {
+			sp = newSampleRatioProcessor(sp, ratio)
+		}
+		if len(c.RedactAttributeKeys) > 0 {
+			sp = &redactProcessor{SpanProcessor: sp, patterns: c.RedactAttributeKeys}
+		}
+		exporters = append(exporters, sp)
+	}
+	if len(c.ErrorExporterEndpoint) > 0 {
+		sp, err := exporterProcessor(ctx, c, errorExporterConfig(c))
 		if err != nil {
-			log.Error("create exporter error", zap.Error(err))
+			log.Error("create error exporter error", zap.Error(err))
 			return nil, err
 		}
+		var rescue sdktrace.SpanProcessor = &errorRescueProcessor{SpanProcessor: sp}
+		if len(c.RedactAttributeKeys) > 0 {
+			rescue = &redactProcessor{SpanProcessor: rescue, patterns: c.RedactAttributeKeys}
+		}
+		exporters = append(exporters, rescue)
+	}
+	// All exporters share the span count of the traces
+	if c.MaxSpansPerTrace > 0 && len(exporters) > 0 {
+		exporters = []sdktrace.SpanProcessor{newSpanLimitProcessor(log, c.MaxSpansPerTrace, exporters)}
+	}
+	for _, sp := range exporters {
+		opts = append(opts, sdktrace.WithSpanProcessor(sp))
+	}
 
-		// Always be sure to batch in production.
-		opts = append(opts,
-			sdktrace.WithBatcher(exp,
-				sdktrace.WithBatchTimeout(c.BatchTimeout),
-				sdktrace.WithMaxExportBatchSize(512),
-				sdktrace.WithMaxQueueSize(2048),
-			),
-		)
+	provider := sdktrace.NewTracerProvider(opts...)
+	cleanup.provider = provider
+	if state.ratio != nil {
+		ratioSamplers.Store(provider, state.ratio)
+	}
+
+	// The propagator and error handler are process-global like the provider
+	if setGlobal(c) {
+		otel.SetTextMapPropagator(propagator)
+		setErrorHandler(log, c.ErrorHandler)
+	}
+	installProvider(ctx, log, c, provider, provider)
+
+	log.Debug("tracer started", startupFields(c, sampler)...)
+
+	return provider, nil
+}
+
+// startupFields returns the log fields describing the effective config.
+// Only the host of the endpoints and the names of the headers are logged,
+// so no credentials end up in the logs.
+func startupFields(c Config, sampler sdktrace.Sampler) []zap.Field {
+	var exporters []string
+	for _, ec := range exporterConfigs(c) {
+		switch {
+		case ec.Batcher == kindFile:
+			exporters = append(exporters, ec.Batcher+" "+ec.FilePath)
+		case !needsEndpoint(ec.Batcher):
+			exporters = append(exporters, ec.Batcher)
+		default:
+			host := "invalid endpoint"
+			if u, err := endpointURL(ec.Endpoint, tlsConfigured(ec)); err == nil {
+				host = u.Host
+			}
+			exporters = append(exporters, ec.Batcher+" "+host)
+		}
 	}
 
-	tp := sdktrace.NewTracerProvider(opts...)
-	otel.SetTracerProvider(tp)
-	otel.SetErrorHandler(otel.ErrorHandlerFunc(func(err error) {
-		log.Error("otel error", zap.Error(err))
-	}))
+	headers := make([]string, 0, len(c.OtlpHeaders))
+	for k := range c.OtlpHeaders {
+		headers = append(headers, k)
+	}
+	sort.Strings(headers)
 
-	return tp, nil
+	return []zap.Field{
+		zap.String("service_name", ServiceName(c)),
+		zap.Strings("exporters", exporters),
+		zap.String("sampler", sampler.Description()),
+		zap.Strings("otlp_headers", headers),
+	}
+}
+
+// setGlobal reports whether the agent installs its provider globally, see Config.SetGlobal.
+func setGlobal(c Config) bool {
+	return c.SetGlobal == nil || *c.SetGlobal
+}
+
+// installProvider installs global as global provider unless disabled by Config.SetGlobal.
+// The provider installed by a previous call is shut down and replaced by provider.
+func installProvider(ctx context.Context, log *zap.Logger, c Config, provider *sdktrace.TracerProvider, global trace.TracerProvider) {
+	if !setGlobal(c) {
+		return
+	}
+
+	tpMu.Lock()
+	otel.SetTracerProvider(global)
+	previous := tp
+	tp = provider
+	serviceName = ServiceName(c)
+	exporting = !c.Disabled && len(exporterConfigs(c)) > 0
+	tpMu.Unlock()
+
+	// The previous provider is no longer reachable through the global provider,
+	// shut it down to not leak its exporters.
+	if previous != nil {
+		shutdownCtx, cancel := context.WithTimeout(ctx, previousShutdownTimeout)
+		defer cancel()
+		if err := ShutdownAgent(shutdownCtx, previous); err != nil {
+			log.Warn("could not shutdown previous tracer", zap.Error(err))
+		}
+	}
 }
@@ -1,10 +1,31 @@
 package trace
 
-import "time"
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"time"
+
+	"github.com/hashicorp/go-multierror"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+)
 
 // TraceName represents the tracing name.
 const TraceName = "wundergraph"
 
+var (
+	// ErrUnknownExporter is returned for an unknown Config.Batcher.
+	ErrUnknownExporter = errors.New("unknown exporter")
+	// ErrInvalidEndpoint is returned for an endpoint the exporter can't send to.
+	ErrInvalidEndpoint = errors.New("invalid OpenTelemetry endpoint")
+)
+
 // A Config is an opentelemetry config.
 type Config struct {
 	Name         string
@@ -12,12 +33,464 @@ type Config struct {
 	Sampler      float64
 	Batcher      string
 	BatchTimeout time.Duration
+	// MaxExportBatchSize is the maximum number of spans sent in one batch.
+	// Defaults to 512.
+	MaxExportBatchSize int
+	// MaxQueueSize is the maximum number of spans buffered before they are dropped.
+	// Defaults to 2048.
+	MaxQueueSize int
+	// ExportTimeout is the maximum duration of a batch export.
+	// For the OTLP HTTP transport it also bounds each export attempt
+	// unless HttpTimeout is set. Defaults to the SDK default of 30s.
+	ExportTimeout time.Duration
+	// HttpTimeout is the maximum duration of a single OTLP HTTP request.
+	// Failed requests may be retried until ExportTimeout is reached if
+	// retries are enabled, so HttpTimeout should be lower than ExportTimeout.
+	// Defaults to ExportTimeout if set, otherwise 10s.
+	HttpTimeout time.Duration
+	// SamplerType is one of always_on, always_off, ratio, parentbased_ratio and remote.
+	// The ratio samplers use Sampler as ratio, where zero samples everything
+	// and values outside of [0, 1] are clamped. Defaults to parentbased_ratio.
+	// The remote sampler polls the strategy from SamplingServerURL and uses
+	// parentbased_ratio until the first strategy is fetched.
+	SamplerType string
+	// SamplerRatio is used as ratio instead of Sampler when set, so an
+	// explicit zero samples nothing. Values outside of [0, 1] are clamped.
+	SamplerRatio *float64
+	// ParentBased overrides the samplers of the parentbased_ratio sampler,
+	// including the fallback of the remote sampler, for spans with a parent.
+	ParentBased ParentBasedSamplers
+	// SamplingServerURL is the Jaeger compatible sampling endpoint of the remote
+	// sampler, e.g. http://jaeger:5778/sampling. The service name is added as query.
+	SamplingServerURL string
+	// SamplingRefreshInterval is the interval of the remote sampler to fetch
+	// the sampling strategy. Defaults to 1m.
+	SamplingRefreshInterval time.Duration
+	// MaxTracesPerSecond caps the number of sampled traces per second.
+	// Spans with a parent follow the sampler of SamplerType, so traces are
+	// either kept or dropped as a whole. Zero disables the limit.
+	MaxTracesPerSecond int
+	// CustomSampler is used as sampler instead of the one selected by
+	// SamplerType and Sampler, e.g. to sample based on the span name.
+	// It's wrapped like the typed samplers, from inner to outer: SamplerRules,
+	// DropSpanNamePrefixes, MaxTracesPerSecond and ForceSampleOnBaggageKey.
+	// With ErrorExporterEndpoint its unsampled spans are still recorded.
+	CustomSampler sdktrace.Sampler
+	// ForceSampleOnBaggageKey samples all spans whose context carries a
+	// baggage member with this key regardless of the sampler, e.g. to debug
+	// specific requests. Empty disables forced sampling.
+	ForceSampleOnBaggageKey string
+	// DropSpanNamePrefixes drops all spans whose name starts with one of the
+	// prefixes, e.g. health checks. Descendants of a dropped span are dropped
+	// too unless they are force sampled by ForceSampleOnBaggageKey.
+	DropSpanNamePrefixes []string
+	// SamplerRules decide the sampling of the spans with matching start
+	// attributes, e.g. to drop http.target=/health. The first matching rule
+	// wins, other spans are left to the sampler.
+	SamplerRules []SamplerRule
 	// OtlpHeaders represents the headers for HTTP transport.
 	// For example:
 	//  Authorization: 'Bearer <token>'
 	OtlpHeaders map[string]string
+	// OtlpHeadersRaw represents additional headers in the format of
+	// OTEL_EXPORTER_OTLP_HEADERS, see ParseHeaders.
+	// OtlpHeaders take precedence over them. They don't apply to Exporters.
+	OtlpHeadersRaw string
+	// BasicAuthUsername and BasicAuthPassword set a basic auth Authorization
+	// header if both are set and the headers contain no Authorization header.
+	BasicAuthUsername string
+	BasicAuthPassword string
 	// OtlpHttpPath represents the path for OTLP HTTP transport.
 	// For example
 	// /v1/traces
+	// Defaults to the path of Endpoint if set, otherwise /v1/traces.
+	OtlpHttpPath string
+	// MetricsPath is the path of the OTLP HTTP metrics receiver used by
+	// StartMetricsAgent. Defaults to /v1/metrics.
+	MetricsPath string
+	// MetricsExportInterval is the interval between the metrics exports of
+	// StartMetricsAgent. Defaults to the SDK default of 60s.
+	MetricsExportInterval time.Duration
+	// Compression is the compression of the OTLP HTTP and gRPC payload,
+	// either none or gzip. Defaults to none.
+	Compression string
+	// Insecure disables TLS for the OTLP transports when true and
+	// enforces it when false. If nil, TLS is only used for https endpoints.
+	Insecure *bool
+	// TLSCertFile and TLSKeyFile are the PEM encoded client certificate
+	// and key presented to the collector for mutual TLS.
+	TLSCertFile string
+	TLSKeyFile  string
+	// TLSCACertFile is the PEM encoded CA certificate used to verify the collector.
+	// When any of the TLS files is set, TLS is used for the OTLP transports.
+	TLSCACertFile string
+	// TLSInsecureSkipVerify disables the verification of the collector
+	// certificate. It should only be used for testing.
+	TLSInsecureSkipVerify bool
+	// ProxyURL is the proxy of the OTLP HTTP and Zipkin exporters, e.g.
+	// http://proxy:3128. If empty, the proxy environment variables are used.
+	// HttpTimeout and RetryConfig apply to the proxied requests, too.
+	ProxyURL string
+	// HTTPClient is used by the OTLP HTTP and Zipkin exporters to send the spans, e.g. to
+	// customize proxies, connection pools or TLS. When set, the TLS options,
+	// ProxyURL and HttpTimeout don't apply to the transport and must be
+	// configured on the client instead. RetryConfig still applies.
+	HTTPClient *http.Client
+	// HeaderProvider returns headers which are added to OtlpHeaders on every
+	// export, e.g. to send rotating credentials. Its headers take precedence.
+	// It must be safe for concurrent use.
+	HeaderProvider func() map[string]string
+	// RetryConfig configures the retries of failed exports for the OTLP HTTP transport.
+	// If nil, the exporter defaults are used. Connection errors and the status codes
+	// 429, 502, 503 and 504 are retried, respecting the Retry-After header.
+	RetryConfig *RetryConfig
+	// Version is the version of the service, recorded as service.version.
+	Version string
+	// DeploymentEnvironment is the environment of the service e.g. production,
+	// recorded as deployment.environment.
+	DeploymentEnvironment string
+	// ResourceAttributes are added to the resource of every span,
+	// e.g. team names. They take precedence over Version and
+	// DeploymentEnvironment, service.name is always taken from Name.
+	ResourceAttributes map[string]string
+	// DefaultSpanAttributes are set on every span when it's started, e.g. a
+	// tenant id. Unlike ResourceAttributes they are span attributes, attributes
+	// with the same key set when starting the span take precedence.
+	DefaultSpanAttributes map[string]string
+	// SchemaURL is the schema URL of the resource, e.g. the SchemaURL of a semconv
+	// package, so backends can translate the attributes. Defaults to a resource
+	// without schema URL. The resource detectors of the SDK use the semconv v1.17.0
+	// schema, detected resources with a different schema URL are not added.
+	SchemaURL string
+	// DetectResources adds host, process and container attributes to the resource.
+	DetectResources bool
+	// ReadEnvResource adds the attributes of OTEL_RESOURCE_ATTRIBUTES to the
+	// resource, the other resource settings take precedence over them.
+	// If nil, it defaults to true. When false, the variable is ignored.
+	ReadEnvResource *bool
+	// Detectors are the names of additional resource detectors, one of
+	// host, process, os, container and env. Failed detections are logged.
+	Detectors []string
+	// ResourceOptions are applied after the Detectors, e.g. to add a detector
+	// this package doesn't provide. The attributes of the other fields take
+	// precedence over the ones of the options.
+	ResourceOptions []resource.Option
+	// Propagators are the names of the propagators used to propagate
+	// the trace context across services, one of tracecontext, baggage,
+	// b3 (single header), b3multi (x-b3-* headers) and jaeger.
+	// Defaults to tracecontext and baggage.
+	Propagators []string
+	// FilePath is the file the file exporter writes the spans to as newline delimited JSON.
+	FilePath string
+	// FileMaxSizeMB is the size in megabytes after which the file is rotated.
+	// Zero disables the rotation.
+	FileMaxSizeMB int
+	// FileMaxBackups is the number of rotated files to keep. Zero keeps none.
+	FileMaxBackups int
+	// PrettyPrint enables human readable output for the stdout exporter.
+	PrettyPrint bool
+	// SpanLimits protect against runaway instrumentation creating oversized spans.
+	SpanLimits SpanLimits
+	// MaxSpansPerTrace caps the number of exported spans of a trace, e.g. to
+	// protect against a retry loop creating a child span per attempt.
+	// Further spans are dropped and a warning is logged once per trace.
+	// Defaults to unlimited.
+	MaxSpansPerTrace int
+	// ProbeOnStart makes the agent startup fail if an exporter endpoint
+	// is unreachable, see ProbeEndpoint.
+	ProbeOnStart bool
+	// SpanProcessors are registered in order before the span processors of the
+	// exporters, e.g. to enrich the spans before they are exported. They are
+	// shut down together with the provider.
+	SpanProcessors []sdktrace.SpanProcessor
+	// IDGenerator generates the trace and span IDs, e.g. the
+	// tracetest.SequentialIDGenerator for predictable IDs in tests.
+	// The random generator of the SDK is used if unset.
+	IDGenerator sdktrace.IDGenerator
+	// ExporterMetrics counts the exported and dropped spans of all exporters if set.
+	ExporterMetrics *ExporterMetrics
+	// ErrorExporterEndpoint enables a best effort rescue of error spans dropped
+	// by the sampler. The spans of unsampled traces are recorded but not sampled,
+	// those ending with an error status are exported with OTLP HTTP to this endpoint.
+	// It shares the headers, TLS and batch settings of the exporter configured on
+	// Config. Only the error spans are exported, not the rest of their trace.
+	// Recording the unsampled spans costs CPU and memory, span processors in
+	// SpanProcessors see them, too.
+	ErrorExporterEndpoint string
+	// RedactAttributeKeys are patterns of attribute keys, e.g. "http.request.header.*",
+	// whose values are replaced by [REDACTED] before the spans are exported.
+	// They apply to span and event attributes and use the syntax of path.Match.
+	RedactAttributeKeys []string
+	// SyncExport exports the spans synchronously when they end instead of
+	// batching them, e.g. to assert on the exported spans in tests.
+	// It should not be used in production.
+	SyncExport bool
+	// Disabled disables the tracing. The returned provider never samples and
+	// a no-op provider is installed globally, so instrumented code has
+	// close to no overhead. All other settings are ignored except SetGlobal.
+	Disabled bool
+	// SetGlobal installs the provider, the propagator and the error handler as
+	// global OpenTelemetry tracer provider, propagator and error handler.
+	// If nil, it defaults to true. When false, the returned provider must be used
+	// directly and the globals are left unchanged.
+	SetGlobal *bool
+	// ErrorHandler handles the errors of the OpenTelemetry SDK, e.g. failed exports.
+	// If nil, the errors are logged. Use ExternalErrorHandler to keep the
+	// handler installed by the application. The handler is process-global
+	// and replaces the one of any previously started agent, it's only
+	// installed if SetGlobal isn't false.
+	ErrorHandler otel.ErrorHandler
+	// Exporters are additional exporters receiving the spans, e.g. a debug
+	// endpoint next to the production collector. All other settings like TLS
+	// and batching are shared with the exporter configured by Batcher and Endpoint.
+	Exporters []ExporterConfig
+	// DialTimeout bounds the connection attempt to the collector
+	// for the OTLP gRPC transport. Zero means the connection
+	// is established lazily in the background.
+	DialTimeout time.Duration
+}
+
+// ServiceName returns the service name reported for c. It's Config.Name,
+// defaulting to OTEL_SERVICE_NAME and then to "unknown_service:" followed
+// by the executable name as specified by OpenTelemetry.
+func ServiceName(c Config) string {
+	if c.Name != "" {
+		return c.Name
+	}
+	if name := os.Getenv(envServiceName); name != "" {
+		return name
+	}
+	return "unknown_service:" + filepath.Base(os.Args[0])
+}
+
+// Validate checks the config for mistakes which would otherwise only surface
+// when the exporter is created, or not at all. All problems are reported at once.
+// An empty Endpoint is valid and disables the export.
+func (c Config) Validate() error {
+	var err error
+
+	if len(c.Endpoint) > 0 || !needsEndpoint(c.Batcher) {
+		headers, headersErr := otlpHeaders(c)
+		if headersErr != nil {
+			err = multierror.Append(err, fmt.Errorf("invalid OpenTelemetry headers: %w", headersErr))
+			headers = c.OtlpHeaders
+		}
+		err = validateExporter(err, c.Batcher, c.Endpoint, tlsConfigured(c), headers)
+	}
+	if len(c.ErrorExporterEndpoint) > 0 {
+		err = validateExporter(err, kindOtlpHttp, c.ErrorExporterEndpoint, tlsConfigured(c), c.OtlpHeaders)
+	}
+	for _, pattern := range c.RedactAttributeKeys {
+		if _, matchErr := path.Match(pattern, ""); matchErr != nil {
+			err = multierror.Append(err, fmt.Errorf("invalid redact attribute key pattern %q: %w", pattern, matchErr))
+		}
+	}
+
+	err = validateDetectors(err, c.Detectors)
+
+	if c.SamplerType == samplerRemote && c.CustomSampler == nil {
+		if u, urlErr := url.Parse(c.SamplingServerURL); urlErr != nil || u.Scheme == "" || u.Host == "" {
+			err = multierror.Append(err, fmt.Errorf("invalid sampling server url %q of sampler %s", c.SamplingServerURL, samplerRemote))
+		}
+	}
+
+	if len(c.ProxyURL) > 0 {
+		if _, proxyErr := parseProxyURL(c.ProxyURL); proxyErr != nil {
+			err = multierror.Append(err, proxyErr)
+		}
+	}
+
+	for i, rule := range c.SamplerRules {
+		if rule.AttributeKey == "" {
+			err = multierror.Append(err, fmt.Errorf("missing attribute key of sampler rule %d", i))
+		}
+	}
+
+	err = validateBasicAuth(err, c)
+
+	hasFileExporter := c.Batcher == kindFile
+	for _, e := range c.Exporters {
+		err = validateExporter(err, e.Batcher, e.Endpoint, tlsConfigured(c), e.OtlpHeaders)
+		hasFileExporter = hasFileExporter || e.Batcher == kindFile
+	}
+	if hasFileExporter && len(c.FilePath) == 0 {
+		err = multierror.Append(err, fmt.Errorf("missing file path for exporter %s", kindFile))
+	}
+
+	return err
+}
+
+func validateDetectors(err error, detectors []string) error {
+	for _, name := range detectors {
+		switch name {
+		case detectorHost, detectorProcess, detectorOS, detectorContainer, detectorEnv:
+		default:
+			err = multierror.Append(err, fmt.Errorf("unknown resource detector: %s", name))
+		}
+	}
+	return err
+}
+
+func validateBasicAuth(err error, c Config) error {
+	if (c.BasicAuthUsername == "") != (c.BasicAuthPassword == "") {
+		err = multierror.Append(err, fmt.Errorf("basic auth requires both username and password"))
+	}
+	return err
+}
+
+func validateExporter(err error, batcher, endpoint string, tls bool, headers map[string]string) error {
+	switch batcher {
+	case kindStdout, kindFile:
+		return err
+	case kindOtlpHttp, kindOtlpGrpc, kindJaeger, kindZipkin:
+	default:
+		return multierror.Append(err, fmt.Errorf("%w: %s", ErrUnknownExporter, batcher))
+	}
+
+	if u, parseErr := endpointURL(endpoint, tls); parseErr != nil {
+		err = multierror.Append(err, fmt.Errorf("%w: %w", ErrInvalidEndpoint, parseErr))
+	} else if len(u.Host) == 0 {
+		err = multierror.Append(err, fmt.Errorf("%w %q: missing host", ErrInvalidEndpoint, endpoint))
+	} else if batcher == kindZipkin {
+		if zipkinErr := validateZipkinEndpoint(u); zipkinErr != nil {
+			err = multierror.Append(err, zipkinErr)
+		}
+	} else if batcher == kindJaeger {
+		if jaegerErr := validateJaegerEndpoint(u); jaegerErr != nil {
+			err = multierror.Append(err, jaegerErr)
+		}
+	}
+
+	for k := range headers {
+		if len(k) == 0 {
+			err = multierror.Append(err, fmt.Errorf("empty header key for exporter %s", batcher))
+		}
+	}
+	return err
+}
+
+// validateZipkinEndpoint checks that u is the full URL of the Zipkin API,
+// as Zipkin doesn't use a default path.
+func validateZipkinEndpoint(u *url.URL) error {
+	if u.Path == "" || u.Path == "/" {
+		return fmt.Errorf("%w %q: missing Zipkin path, e.g. /api/v2/spans", ErrInvalidEndpoint, u.String())
+	}
+	return nil
+}
+
+// validateJaegerEndpoint checks that u points to the OTLP HTTP receiver of Jaeger,
+// e.g. http://localhost:4318. The Thrift agent and collector endpoints aren't supported.
+func validateJaegerEndpoint(u *url.URL) error {
+	if u.Scheme == "udp" || u.Port() == "6831" || u.Port() == "6832" || u.Port() == "14268" || u.Path == "/api/traces" {
+		return fmt.Errorf("%w %q: the Jaeger Thrift protocol is not supported, use the OTLP HTTP receiver of Jaeger on port 4318 instead", ErrInvalidEndpoint, u.String())
+	}
+	if u.Path != "" && u.Path != "/" && u.Path != defaultOtlpHttpPath {
+		return fmt.Errorf("%w %q: the path of the OTLP HTTP receiver of Jaeger is %s", ErrInvalidEndpoint, u.String(), defaultOtlpHttpPath)
+	}
+	return nil
+}
+
+// SpanLimits limit the size of the spans. Zero values use the SDK defaults.
+type SpanLimits struct {
+	// AttributeCountLimit is the maximum number of attributes per span. Defaults to 128.
+	AttributeCountLimit int
+	// EventCountLimit is the maximum number of events per span. Defaults to 128.
+	EventCountLimit int
+	// LinkCountLimit is the maximum number of links per span. Defaults to 128.
+	LinkCountLimit int
+	// AttributeValueLengthLimit is the maximum length of string attribute values.
+	// Defaults to unlimited.
+	AttributeValueLengthLimit int
+}
+
+func (l SpanLimits) sdkSpanLimits() sdktrace.SpanLimits {
+	limits := sdktrace.NewSpanLimits()
+	if l.AttributeCountLimit > 0 {
+		limits.AttributeCountLimit = l.AttributeCountLimit
+	}
+	if l.EventCountLimit > 0 {
+		limits.EventCountLimit = l.EventCountLimit
+	}
+	if l.LinkCountLimit > 0 {
+		limits.LinkCountLimit = l.LinkCountLimit
+	}
+	if l.AttributeValueLengthLimit > 0 {
+		limits.AttributeValueLengthLimit = l.AttributeValueLengthLimit
+	}
+	return limits
+}
+
+// ParentBasedSamplers are the samplers of the parentbased_ratio sampler
+// for spans with a parent. Nil samplers use the sdktrace.ParentBased defaults,
+// which follow the sampling decision of the parent.
+type ParentBasedSamplers struct {
+	// RemoteParentSampled samples spans with a sampled remote parent.
+	RemoteParentSampled sdktrace.Sampler
+	// RemoteParentNotSampled samples spans with a not sampled remote parent.
+	RemoteParentNotSampled sdktrace.Sampler
+	// LocalParentSampled samples spans with a sampled local parent.
+	LocalParentSampled sdktrace.Sampler
+	// LocalParentNotSampled samples spans with a not sampled local parent.
+	LocalParentNotSampled sdktrace.Sampler
+}
+
+func (s ParentBasedSamplers) options() []sdktrace.ParentBasedSamplerOption {
+	var opts []sdktrace.ParentBasedSamplerOption
+	if s.RemoteParentSampled != nil {
+		opts = append(opts, sdktrace.WithRemoteParentSampled(s.RemoteParentSampled))
+	}
+	if s.RemoteParentNotSampled != nil {
+		opts = append(opts, sdktrace.WithRemoteParentNotSampled(s.RemoteParentNotSampled))
+	}
+	if s.LocalParentSampled != nil {
+		opts = append(opts, sdktrace.WithLocalParentSampled(s.LocalParentSampled))
+	}
+	if s.LocalParentNotSampled != nil {
+		opts = append(opts, sdktrace.WithLocalParentNotSampled(s.LocalParentNotSampled))
+	}
+	return opts
+}
+
+// An ExporterConfig configures an additional exporter.
+type ExporterConfig struct {
+	Batcher  string
+	Endpoint string
+	// OtlpHeaders represents the headers for HTTP transport.
+	OtlpHeaders map[string]string
+	// OtlpHttpPath represents the path for OTLP HTTP transport.
 	OtlpHttpPath string
+	// SampleRatio is the ratio of the traces sent to this exporter, e.g. 1 for
+	// a debug exporter receiving everything. Zero uses the ratio of the Config.
+	// It only applies to the ratio based samplers: the head samples the
+	// highest ratio of all exporters and the others drop the surplus spans
+	// after they ended, so the overhead of recording all these spans remains.
+	// SetSampleRatio changes the head ratio.
+	SampleRatio float64
+}
+
+// A RetryConfig configures the retries of failed exports.
+// Zero intervals use the exporter defaults.
+type RetryConfig struct {
+	// Enabled enables the retries. When false, failed exports are dropped immediately.
+	Enabled bool
+	// InitialInterval is the time to wait after the first failure before retrying.
+	// Defaults to 5s.
+	InitialInterval time.Duration
+	// MaxInterval is the upper bound on the backoff interval.
+	// Defaults to 30s.
+	MaxInterval time.Duration
+	// MaxElapsedTime is the maximum time spent retrying a batch.
+	// Defaults to 1m.
+	MaxElapsedTime time.Duration
+}
+
+// A SamplerRule matches the spans started with the attribute
+// AttributeKey=AttributeValue. Matching spans are dropped unless Sample is set.
+type SamplerRule struct {
+	AttributeKey string
+	// AttributeValue is compared to the string representation of the value,
+	// e.g. "true" for a boolean attribute.
+	AttributeValue string
+	// Sample samples the matching spans instead of dropping them.
+	Sample bool
 }
@@ -0,0 +1,96 @@
+package trace
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/hashicorp/go-multierror"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/otel/attribute"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/trace"
+	"go.uber.org/zap"
+)
+
+func TestConfigValidate(t *testing.T) {
+	valid := []Config{
+		{Name: "no exporter"},
+		{Name: "stdout", Batcher: kindStdout},
+		{Name: "otlphttp", Batcher: kindOtlpHttp, Endpoint: "http://localhost:4318", Sampler: 1},
+		{Name: "without scheme", Batcher: kindOtlpGrpc, Endpoint: "localhost:4317"},
+		{Name: "exporters", Exporters: []ExporterConfig{{Batcher: kindZipkin, Endpoint: "http://localhost:9411/api/v2/spans"}}},
+	}
+	for _, c := range valid {
+		t.Run(c.Name, func(t *testing.T) {
+			assert.NoError(t, c.Validate())
+		})
+	}
+
+	t.Run("invalid", func(t *testing.T) {
+		c := Config{
+			Batcher:  kindOtlpHttp,
+			Endpoint: "http:///v1/traces",
+			OtlpHeaders: map[string]string{
+				"": "value",
+			},
+			OtlpHeadersRaw: "malformed",
+			Exporters: []ExporterConfig{
+				{Batcher: "otlp", Endpoint: "http://localhost:4318"},
+			},
+			SamplerRules: []SamplerRule{{AttributeValue: "/health"}},
+			SamplerType:  samplerRemote,
+		}
+		err := c.Validate()
+		require.Error(t, err)
+
+		var merr *multierror.Error
+		require.ErrorAs(t, err, &merr)
+		assert.Len(t, merr.Errors, 6)
+		assert.ErrorContains(t, err, "invalid OpenTelemetry headers")
+		assert.ErrorContains(t, err, `invalid OpenTelemetry endpoint "http:///v1/traces": missing host`)
+		assert.ErrorContains(t, err, "empty header key")
+		assert.ErrorContains(t, err, "unknown exporter: otlp")
+		assert.ErrorIs(t, err, ErrUnknownExporter)
+		assert.ErrorIs(t, err, ErrInvalidEndpoint)
+		assert.ErrorContains(t, err, "missing attribute key of sampler rule 0")
+		assert.ErrorContains(t, err, `invalid sampling server url "" of sampler remote`)
+	})
+}
+
+func TestSpanLimits(t *testing.T) {
+	assert.Equal(t, sdktrace.NewSpanLimits(), SpanLimits{}.sdkSpanLimits())
+
+	tp, err := StartAgent(zap.NewNop(), Config{
+		Name:    "foo",
+		Sampler: 1,
+		SpanLimits: SpanLimits{
+			AttributeCountLimit:       2,
+			AttributeValueLengthLimit: 3,
+		},
+	})
+	require.NoError(t, err)
+
+	_, span := tp.Tracer(TraceName).Start(context.Background(), "span", trace.WithAttributes(
+		attribute.String("a", "value"),
+		attribute.String("b", "value"),
+		attribute.String("c", "value"),
+	))
+	span.End()
+
+	ro := span.(sdktrace.ReadOnlySpan)
+	assert.Len(t, ro.Attributes(), 2)
+	assert.Equal(t, 1, ro.DroppedAttributes())
+	assert.Equal(t, "val", ro.Attributes()[0].Value.AsString())
+}
+
+func TestServiceName(t *testing.T) {
+	t.Setenv(envServiceName, "")
+	assert.Equal(t, "unknown_service:"+filepath.Base(os.Args[0]), ServiceName(Config{}))
+
+	t.Setenv(envServiceName, "env")
+	assert.Equal(t, "env", ServiceName(Config{}))
+	assert.Equal(t, "foo", ServiceName(Config{Name: "foo"}))
+}
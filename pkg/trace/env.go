@@ -0,0 +1,107 @@
+package trace
+
+import (
+	"fmt"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+)
+
+const (
+	envOtlpEndpoint  = "OTEL_EXPORTER_OTLP_ENDPOINT"
+	envOtlpHeaders   = "OTEL_EXPORTER_OTLP_HEADERS"
+	envServiceName   = "OTEL_SERVICE_NAME"
+	envTracesSampler = "OTEL_TRACES_SAMPLER"
+	envSamplerArg    = "OTEL_TRACES_SAMPLER_ARG"
+	envResourceAttrs = "OTEL_RESOURCE_ATTRIBUTES"
+)
+
+// ConfigFromEnv creates a Config from the standard OTEL_* environment variables.
+// The endpoint is exported to with OTLP over HTTP.
+func ConfigFromEnv() (Config, error) {
+	c := Config{
+		Name: os.Getenv(envServiceName),
+	}
+
+	if endpoint := os.Getenv(envOtlpEndpoint); endpoint != "" {
+		c.Endpoint = endpoint
+		c.Batcher = kindOtlpHttp
+	}
+
+	if v := os.Getenv(envOtlpHeaders); v != "" {
+		headers, err := ParseHeaders(v)
+		if err != nil {
+			return Config{}, fmt.Errorf("invalid %s: %w", envOtlpHeaders, err)
+		}
+		c.OtlpHeaders = headers
+	}
+
+	// The sampler argument only applies to the ratio samplers
+	var ratioSampler bool
+	switch v := os.Getenv(envTracesSampler); v {
+	case "", "parentbased_traceidratio":
+		c.SamplerType = samplerParentBasedRatio
+		ratioSampler = true
+	case "traceidratio":
+		c.SamplerType = samplerRatio
+		ratioSampler = true
+	case "parentbased_always_on":
+		// An unset ratio samples everything
+		c.SamplerType = samplerParentBasedRatio
+	case "always_on":
+		c.SamplerType = samplerAlwaysOn
+	case "always_off":
+		c.SamplerType = samplerAlwaysOff
+	default:
+		return Config{}, fmt.Errorf("invalid %s: unsupported sampler: %s", envTracesSampler, v)
+	}
+
+	if v := os.Getenv(envSamplerArg); v != "" && ratioSampler {
+		ratio, err := strconv.ParseFloat(v, 64)
+		if err != nil {
+			return Config{}, fmt.Errorf("invalid %s: %w", envSamplerArg, err)
+		}
+		c.Sampler = ratio
+	}
+
+	return c, nil
+}
+
+// parseKeyValues parses the format of the OTEL_* environment variables with
+// multiple values, a comma separated list of key=value pairs with percent
+// encoded values. A malformed pair fails the parsing unless skip is set, then
+// its error is passed to skip and the pair is ignored. The kind of the values
+// is used in the errors.
+func parseKeyValues(s, kind string, skip func(error)) (map[string]string, error) {
+	values := make(map[string]string)
+	for _, pair := range strings.Split(s, ",") {
+		if strings.TrimSpace(pair) == "" {
+			continue
+		}
+		key, value, err := parseKeyValue(pair, kind)
+		if err != nil {
+			if skip == nil {
+				return nil, err
+			}
+			skip(err)
+			continue
+		}
+		values[key] = value
+	}
+	return values, nil
+}
+
+// parseKeyValue parses a single key=value pair of parseKeyValues.
+func parseKeyValue(pair, kind string) (string, string, error) {
+	key, value, ok := strings.Cut(pair, "=")
+	key = strings.TrimSpace(key)
+	if !ok || key == "" {
+		return "", "", fmt.Errorf("malformed %s: %q", kind, pair)
+	}
+	value, err := url.PathUnescape(strings.TrimSpace(value))
+	if err != nil {
+		return "", "", fmt.Errorf("malformed %s value for %s: %w", kind, key, err)
+	}
+	return key, value, nil
+}
@@ -0,0 +1,63 @@
+package trace
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestConfigFromEnv(t *testing.T) {
+	t.Run("empty", func(t *testing.T) {
+		c, err := ConfigFromEnv()
+		require.NoError(t, err)
+		assert.Equal(t, Config{SamplerType: samplerParentBasedRatio}, c)
+	})
+
+	t.Run("all", func(t *testing.T) {
+		t.Setenv(envServiceName, "foo")
+		t.Setenv(envOtlpEndpoint, "https://collector:4318")
+		t.Setenv(envOtlpHeaders, "Authorization=Bearer%20token, x-tenant = bar ")
+		t.Setenv(envTracesSampler, "traceidratio")
+		t.Setenv(envSamplerArg, "0.25")
+
+		c, err := ConfigFromEnv()
+		require.NoError(t, err)
+		assert.Equal(t, Config{
+			Name:     "foo",
+			Endpoint: "https://collector:4318",
+			Batcher:  kindOtlpHttp,
+			OtlpHeaders: map[string]string{
+				"Authorization": "Bearer token",
+				"x-tenant":      "bar",
+			},
+			SamplerType: samplerRatio,
+			Sampler:     0.25,
+		}, c)
+		assert.NoError(t, c.Validate())
+	})
+
+	t.Run("sampler argument is ignored for non ratio samplers", func(t *testing.T) {
+		t.Setenv(envTracesSampler, "always_off")
+		t.Setenv(envSamplerArg, "0.25")
+
+		c, err := ConfigFromEnv()
+		require.NoError(t, err)
+		assert.Equal(t, Config{SamplerType: samplerAlwaysOff}, c)
+	})
+
+	t.Run("errors", func(t *testing.T) {
+		tests := map[string]string{
+			envOtlpHeaders:   "foo",
+			envTracesSampler: "jaeger_remote",
+			envSamplerArg:    "half",
+		}
+		for key, value := range tests {
+			t.Run(key, func(t *testing.T) {
+				t.Setenv(key, value)
+				_, err := ConfigFromEnv()
+				assert.ErrorContains(t, err, "invalid "+key)
+			})
+		}
+	})
+}
@@ -0,0 +1,66 @@
+package trace
+
+import (
+	"fmt"
+
+	"go.opentelemetry.io/otel/codes"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// recordUnsampledSampler records the spans the delegate drops without sampling
+// them, so the errorRescueProcessor sees the error spans of unsampled traces.
+type recordUnsampledSampler struct {
+	delegate sdktrace.Sampler
+}
+
+func (s recordUnsampledSampler) ShouldSample(p sdktrace.SamplingParameters) sdktrace.SamplingResult {
+	res := s.delegate.ShouldSample(p)
+	if res.Decision == sdktrace.Drop {
+		res.Decision = sdktrace.RecordOnly
+	}
+	return res
+}
+
+func (s recordUnsampledSampler) Description() string {
+	return fmt.Sprintf("RecordUnsampled{%s}", s.delegate.Description())
+}
+
+// errorRescueProcessor passes the ended spans of unsampled traces with an
+// error status to the wrapped processor as sampled spans, the processors of
+// the SDK ignore unsampled spans. Sampled spans are exported by the regular
+// exporters and skipped. Only the error spans themselves are rescued, not the
+// rest of their trace.
+type errorRescueProcessor struct {
+	sdktrace.SpanProcessor
+}
+
+func (p *errorRescueProcessor) OnEnd(s sdktrace.ReadOnlySpan) {
+	if s.SpanContext().IsSampled() || s.Status().Code != codes.Error {
+		return
+	}
+	p.SpanProcessor.OnEnd(&rescuedSpan{ReadOnlySpan: s})
+}
+
+// rescuedSpan is a sdktrace.ReadOnlySpan reporting its span context as sampled.
+type rescuedSpan struct {
+	sdktrace.ReadOnlySpan
+}
+
+func (s *rescuedSpan) SpanContext() trace.SpanContext {
+	sc := s.ReadOnlySpan.SpanContext()
+	return sc.WithTraceFlags(sc.TraceFlags().WithSampled(true))
+}
+
+// errorExporterConfig returns the config of the exporter of the rescued error
+// spans, it exports with OTLP HTTP to Config.ErrorExporterEndpoint and shares
+// the transport settings of c.
+func errorExporterConfig(c Config) Config {
+	ec := c
+	ec.Batcher = kindOtlpHttp
+	ec.Endpoint = c.ErrorExporterEndpoint
+	// The path comes from ErrorExporterEndpoint or defaults to /v1/traces
+	ec.OtlpHttpPath = ""
+	ec.Exporters = nil
+	return ec
+}
@@ -0,0 +1,95 @@
+package trace
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/otel/codes"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"go.uber.org/zap"
+)
+
+func TestRecordUnsampledSampler(t *testing.T) {
+	s := recordUnsampledSampler{delegate: sdktrace.NeverSample()}
+	assert.Equal(t, sdktrace.RecordOnly, s.ShouldSample(sdktrace.SamplingParameters{}).Decision)
+	assert.Equal(t, "RecordUnsampled{AlwaysOffSampler}", s.Description())
+
+	s = recordUnsampledSampler{delegate: sdktrace.AlwaysSample()}
+	assert.Equal(t, sdktrace.RecordAndSample, s.ShouldSample(sdktrace.SamplingParameters{}).Decision)
+}
+
+func TestErrorRescueProcessor(t *testing.T) {
+	sr := tracetest.NewSpanRecorder()
+	rescue := &errorRescueProcessor{SpanProcessor: sr}
+
+	unsampled := sdktrace.NewTracerProvider(
+		sdktrace.WithSampler(recordUnsampledSampler{delegate: sdktrace.NeverSample()}),
+		sdktrace.WithSpanProcessor(rescue),
+	)
+	sampled := sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(rescue))
+
+	_, span := unsampled.Tracer(TraceName).Start(context.Background(), "error")
+	span.SetStatus(codes.Error, "failed")
+	span.End()
+	_, span = unsampled.Tracer(TraceName).Start(context.Background(), "ok")
+	span.End()
+	// Exported by the regular exporters
+	_, span = sampled.Tracer(TraceName).Start(context.Background(), "sampled error")
+	span.SetStatus(codes.Error, "failed")
+	span.End()
+
+	ended := sr.Ended()
+	require.Len(t, ended, 1)
+	assert.Equal(t, "error", ended[0].Name())
+	assert.True(t, ended[0].SpanContext().IsSampled())
+}
+
+func TestStartAgentErrorExporterEndpoint(t *testing.T) {
+	var (
+		mu    sync.Mutex
+		paths []string
+	)
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		defer mu.Unlock()
+		paths = append(paths, r.URL.Path)
+	}))
+	defer ts.Close()
+
+	var m ExporterMetrics
+	tp, err := StartAgent(zap.NewNop(), Config{
+		Name:                  "foo",
+		Batcher:               kindFile,
+		FilePath:              filepath.Join(t.TempDir(), "spans.jsonl"),
+		SamplerType:           samplerAlwaysOff,
+		SyncExport:            true,
+		ExporterMetrics:       &m,
+		ErrorExporterEndpoint: ts.URL,
+	})
+	require.NoError(t, err)
+
+	_, span := tp.Tracer(TraceName).Start(context.Background(), "error")
+	span.SetStatus(codes.Error, "failed")
+	span.End()
+	_, span = tp.Tracer(TraceName).Start(context.Background(), "ok")
+	span.End()
+	require.NoError(t, ShutdownAgent(context.Background(), tp))
+
+	mu.Lock()
+	defer mu.Unlock()
+	assert.Equal(t, []string{"/v1/traces"}, paths)
+	// Only the rescued span is exported
+	assert.Equal(t, int64(1), m.SpansExported())
+
+	t.Run("invalid endpoint", func(t *testing.T) {
+		_, err := StartAgent(zap.NewNop(), Config{Name: "foo", ErrorExporterEndpoint: "http:///v1/traces"})
+		assert.ErrorIs(t, err, ErrInvalidEndpoint)
+	})
+}
@@ -0,0 +1,28 @@
+package trace
+
+import (
+	"go.opentelemetry.io/otel"
+	"go.uber.org/zap"
+)
+
+// ExternalErrorHandler can be used as Config.ErrorHandler to keep the
+// global OpenTelemetry error handler managed by the application.
+var ExternalErrorHandler otel.ErrorHandler = externalErrorHandler{}
+
+type externalErrorHandler struct{}
+
+func (externalErrorHandler) Handle(error) {}
+
+// setErrorHandler installs the given handler as global OpenTelemetry error handler.
+// If the handler is nil, errors are logged.
+func setErrorHandler(log *zap.Logger, h otel.ErrorHandler) {
+	switch h.(type) {
+	case externalErrorHandler:
+		return
+	case nil:
+		h = otel.ErrorHandlerFunc(func(err error) {
+			log.Error("otel error", zap.Error(err))
+		})
+	}
+	otel.SetErrorHandler(h)
+}
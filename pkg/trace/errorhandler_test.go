@@ -0,0 +1,44 @@
+package trace
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"go.opentelemetry.io/otel"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zaptest/observer"
+)
+
+func TestSetErrorHandler(t *testing.T) {
+	errExport := errors.New("export failed")
+
+	t.Run("log by default", func(t *testing.T) {
+		core, logs := observer.New(zap.ErrorLevel)
+		setErrorHandler(zap.New(core), nil)
+
+		otel.Handle(errExport)
+		assert.Equal(t, 1, logs.FilterMessage("otel error").Len())
+	})
+
+	t.Run("custom handler", func(t *testing.T) {
+		var handled []error
+		setErrorHandler(zap.NewNop(), otel.ErrorHandlerFunc(func(err error) {
+			handled = append(handled, err)
+		}))
+
+		otel.Handle(errExport)
+		assert.Equal(t, []error{errExport}, handled)
+	})
+
+	t.Run("external handler", func(t *testing.T) {
+		var handled []error
+		otel.SetErrorHandler(otel.ErrorHandlerFunc(func(err error) {
+			handled = append(handled, err)
+		}))
+		setErrorHandler(zap.NewNop(), ExternalErrorHandler)
+
+		otel.Handle(errExport)
+		assert.Equal(t, []error{errExport}, handled)
+	})
+}
@@ -0,0 +1,51 @@
+package trace
+
+import (
+	"context"
+	"sync/atomic"
+
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+)
+
+// ExporterMetrics counts the spans handled by the exporters of an agent.
+// Set Config.ExporterMetrics to collect them, e.g. to alert on trace
+// delivery problems. Spans dropped because the queue of the batch span
+// processor is full never reach the exporter and aren't counted.
+type ExporterMetrics struct {
+	spansExported  atomic.Int64
+	spansDropped   atomic.Int64
+	exportFailures atomic.Int64
+}
+
+// SpansExported returns the number of successfully exported spans.
+func (m *ExporterMetrics) SpansExported() int64 {
+	return m.spansExported.Load()
+}
+
+// SpansDropped returns the number of spans lost in failed exports.
+func (m *ExporterMetrics) SpansDropped() int64 {
+	return m.spansDropped.Load()
+}
+
+// ExportFailures returns the number of failed exports.
+func (m *ExporterMetrics) ExportFailures() int64 {
+	return m.exportFailures.Load()
+}
+
+// meteredExporter is a sdktrace.SpanExporter which records
+// the results of the wrapped exporter in ExporterMetrics.
+type meteredExporter struct {
+	sdktrace.SpanExporter
+	metrics *ExporterMetrics
+}
+
+func (e *meteredExporter) ExportSpans(ctx context.Context, spans []sdktrace.ReadOnlySpan) error {
+	err := e.SpanExporter.ExportSpans(ctx, spans)
+	if err != nil {
+		e.metrics.exportFailures.Add(1)
+		e.metrics.spansDropped.Add(int64(len(spans)))
+		return err
+	}
+	e.metrics.spansExported.Add(int64(len(spans)))
+	return nil
+}
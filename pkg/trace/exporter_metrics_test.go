@@ -0,0 +1,55 @@
+package trace
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"go.uber.org/zap"
+)
+
+type failingExporter struct {
+	sdktrace.SpanExporter
+}
+
+func (failingExporter) ExportSpans(ctx context.Context, spans []sdktrace.ReadOnlySpan) error {
+	return errors.New("export failed")
+}
+
+func TestMeteredExporter(t *testing.T) {
+	var m ExporterMetrics
+	spans := tracetest.SpanStubs{{Name: "a"}, {Name: "b"}}.Snapshots()
+
+	exp := &meteredExporter{SpanExporter: tracetest.NewInMemoryExporter(), metrics: &m}
+	require.NoError(t, exp.ExportSpans(context.Background(), spans))
+
+	exp = &meteredExporter{SpanExporter: failingExporter{}, metrics: &m}
+	assert.Error(t, exp.ExportSpans(context.Background(), spans))
+	assert.Error(t, exp.ExportSpans(context.Background(), spans[:1]))
+
+	assert.Equal(t, int64(2), m.SpansExported())
+	assert.Equal(t, int64(3), m.SpansDropped())
+	assert.Equal(t, int64(2), m.ExportFailures())
+}
+
+func TestStartAgentExporterMetrics(t *testing.T) {
+	var m ExporterMetrics
+	tp, err := StartAgent(zap.NewNop(), Config{
+		Name:            "foo",
+		Batcher:         kindStdout,
+		ExporterMetrics: &m,
+	})
+	require.NoError(t, err)
+	defer func() {
+		assert.NoError(t, tp.Shutdown(context.Background()))
+	}()
+
+	_, span := tp.Tracer(TraceName).Start(context.Background(), "span")
+	span.End()
+
+	assert.Equal(t, int64(1), m.SpansExported())
+}
@@ -0,0 +1,108 @@
+package trace
+
+import (
+	"context"
+	"sync"
+
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// hasRatioSampler reports whether the head sampler of c is a ratio based sampler.
+func hasRatioSampler(c Config) bool {
+	if c.CustomSampler != nil {
+		return false
+	}
+	switch c.SamplerType {
+	case "", samplerRatio, samplerParentBasedRatio:
+		return true
+	default:
+		return false
+	}
+}
+
+// headSampleRatio returns the ratio of the head sampler, the highest ratio
+// of the exporters, so every exporter gets its share of the traces.
+// It's only meaningful for the ratio based samplers.
+func headSampleRatio(configs []Config) float64 {
+	var ratio float64
+	for _, ec := range configs {
+		if r := configRatio(ec); r > ratio {
+			ratio = r
+		}
+	}
+	return ratio
+}
+
+// exporterSampleRatio returns the ratio of the traces ec exports and whether
+// the spans of it must be filtered, because the head samples more of them.
+func exporterSampleRatio(c, ec Config, headRatio float64) (float64, bool) {
+	if !hasRatioSampler(c) {
+		return 0, false
+	}
+	ratio := configRatio(ec)
+	return ratio, ratio < headRatio
+}
+
+// sampleRatioProcessor passes the ended spans of the traces selected by the
+// trace id ratio to the wrapped processor. The selection is the same as the one
+// of the trace id ratio based sampler, so traces are kept or dropped as a whole.
+// Traces entering with a sampled remote parent are always passed on, they were
+// sampled by the decision of the caller. Their open spans are tracked, so the
+// descendants of the local roots are passed on too.
+type sampleRatioProcessor struct {
+	sdktrace.SpanProcessor
+	sampler sdktrace.Sampler
+
+	mu sync.Mutex
+	// passed maps the traces with a sampled remote parent to their open spans
+	passed map[trace.TraceID]int
+}
+
+func newSampleRatioProcessor(sp sdktrace.SpanProcessor, ratio float64) *sampleRatioProcessor {
+	return &sampleRatioProcessor{
+		SpanProcessor: sp,
+		sampler:       sdktrace.TraceIDRatioBased(ratio),
+		passed:        make(map[trace.TraceID]int),
+	}
+}
+
+func (p *sampleRatioProcessor) OnStart(parent context.Context, s sdktrace.ReadWriteSpan) {
+	traceID := s.SpanContext().TraceID()
+	p.mu.Lock()
+	if remote := s.Parent(); remote.IsRemote() && remote.IsSampled() {
+		p.passed[traceID]++
+	} else if _, ok := p.passed[traceID]; ok {
+		p.passed[traceID]++
+	}
+	p.mu.Unlock()
+	p.SpanProcessor.OnStart(parent, s)
+}
+
+func (p *sampleRatioProcessor) OnEnd(s sdktrace.ReadOnlySpan) {
+	if p.pass(s.SpanContext().TraceID()) {
+		p.SpanProcessor.OnEnd(s)
+		return
+	}
+	res := p.sampler.ShouldSample(sdktrace.SamplingParameters{TraceID: s.SpanContext().TraceID()})
+	if res.Decision == sdktrace.RecordAndSample {
+		p.SpanProcessor.OnEnd(s)
+	}
+}
+
+// pass reports whether the span of traceID belongs to a trace with a sampled
+// remote parent and forgets the trace after its last open span ended.
+func (p *sampleRatioProcessor) pass(traceID trace.TraceID) bool {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	open, ok := p.passed[traceID]
+	if !ok {
+		return false
+	}
+	if open <= 1 {
+		delete(p.passed, traceID)
+	} else {
+		p.passed[traceID] = open - 1
+	}
+	return true
+}
@@ -0,0 +1,148 @@
+package trace
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"go.opentelemetry.io/otel/trace"
+	"go.uber.org/zap"
+)
+
+func TestSampleRatioProcessor(t *testing.T) {
+	exporter := tracetest.NewInMemoryExporter()
+	tp := sdktrace.NewTracerProvider(
+		sdktrace.WithSpanProcessor(newSampleRatioProcessor(sdktrace.NewSimpleSpanProcessor(exporter), 0.5)),
+	)
+
+	const traces = 1000
+	for i := 0; i < traces; i++ {
+		ctx, root := tp.Tracer(TraceName).Start(context.Background(), "root")
+		_, child := tp.Tracer(TraceName).Start(ctx, "child")
+		child.End()
+		root.End()
+	}
+
+	// Traces are kept or dropped as a whole
+	spans := exporter.GetSpans()
+	perTrace := make(map[trace.TraceID]int)
+	for _, s := range spans {
+		perTrace[s.SpanContext.TraceID()]++
+	}
+	for _, n := range perTrace {
+		assert.Equal(t, 2, n)
+	}
+	assert.InDelta(t, traces/2, len(perTrace), traces/10)
+
+	// The calls of sampled remote parents are kept
+	exporter.Reset()
+	never := newSampleRatioProcessor(sdktrace.NewSimpleSpanProcessor(exporter), 0)
+	tp = sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(never))
+	_, span := tp.Tracer(TraceName).Start(sampledParentContext(true), "child")
+	span.End()
+	_, span = tp.Tracer(TraceName).Start(context.Background(), "root")
+	span.End()
+	spans = exporter.GetSpans()
+	require.Len(t, spans, 1)
+	assert.Equal(t, "child", spans[0].Name)
+	assert.Empty(t, never.passed)
+}
+
+func TestSampleRatioProcessorRemoteParent(t *testing.T) {
+	exporter := tracetest.NewInMemoryExporter()
+	never := newSampleRatioProcessor(sdktrace.NewSimpleSpanProcessor(exporter), 0)
+	tp := sdktrace.NewTracerProvider(
+		sdktrace.WithSampler(sdktrace.ParentBased(sdktrace.AlwaysSample())),
+		sdktrace.WithSpanProcessor(never),
+	)
+
+	// The descendants of the server span are kept, even when they end after it
+	ctx, server := tp.Tracer(TraceName).Start(sampledParentContext(true), "server")
+	ctx, child := tp.Tracer(TraceName).Start(ctx, "child")
+	_, grandchild := tp.Tracer(TraceName).Start(ctx, "grandchild")
+	grandchild.End()
+	server.End()
+	child.End()
+
+	spans := exporter.GetSpans()
+	require.Len(t, spans, 3)
+	assert.Equal(t, []string{"grandchild", "server", "child"}, []string{spans[0].Name, spans[1].Name, spans[2].Name})
+	assert.Empty(t, never.passed)
+
+	// Traces started locally are still filtered
+	exporter.Reset()
+	ctx, root := tp.Tracer(TraceName).Start(context.Background(), "root")
+	_, child = tp.Tracer(TraceName).Start(ctx, "child")
+	child.End()
+	root.End()
+	assert.Empty(t, exporter.GetSpans())
+}
+
+func TestExporterSampleRatio(t *testing.T) {
+	c := Config{
+		Sampler:  0.1,
+		Batcher:  kindOtlpHttp,
+		Endpoint: "http://localhost:4318",
+		Exporters: []ExporterConfig{
+			{Batcher: kindStdout, SampleRatio: 1},
+			{Batcher: kindZipkin},
+		},
+	}
+	configs := exporterConfigs(c)
+	require.Len(t, configs, 3)
+	head := headSampleRatio(configs)
+	assert.Equal(t, float64(1), head)
+
+	ratio, filter := exporterSampleRatio(c, configs[0], head)
+	assert.True(t, filter)
+	assert.Equal(t, 0.1, ratio)
+	_, filter = exporterSampleRatio(c, configs[1], head)
+	assert.False(t, filter)
+	ratio, filter = exporterSampleRatio(c, configs[2], head)
+	assert.True(t, filter)
+	assert.Equal(t, 0.1, ratio)
+
+	c.SamplerType = samplerAlwaysOn
+	_, filter = exporterSampleRatio(c, configs[0], head)
+	assert.False(t, filter)
+}
+
+func TestStartAgentExporterSampleRatio(t *testing.T) {
+	var (
+		mu       sync.Mutex
+		received []string
+	)
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		defer mu.Unlock()
+		received = append(received, r.URL.Path)
+		w.WriteHeader(http.StatusAccepted)
+	}))
+	defer ts.Close()
+
+	tp, err := StartAgent(zap.NewNop(), Config{
+		Name:       "foo",
+		Sampler:    1e-12,
+		Batcher:    kindOtlpHttp,
+		Endpoint:   ts.URL,
+		SyncExport: true,
+		Exporters:  []ExporterConfig{{Batcher: kindZipkin, Endpoint: ts.URL + "/api/v2/spans", SampleRatio: 1}},
+	})
+	require.NoError(t, err)
+
+	_, span := tp.Tracer(TraceName).Start(context.Background(), "span")
+	span.End()
+	require.NoError(t, ShutdownAgent(context.Background(), tp))
+
+	// The head samples everything for the debug exporter only
+	assert.True(t, span.SpanContext().IsSampled())
+	mu.Lock()
+	defer mu.Unlock()
+	assert.Equal(t, []string{"/api/v2/spans"}, received)
+}
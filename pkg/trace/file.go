@@ -0,0 +1,144 @@
+package trace
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"sync"
+
+	"github.com/hashicorp/go-multierror"
+	"go.opentelemetry.io/otel/exporters/stdout/stdouttrace"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+)
+
+// newFileExporter creates an exporter writing the spans as newline delimited JSON to Config.FilePath.
+func newFileExporter(c Config) (sdktrace.SpanExporter, error) {
+	f, err := newRotatingFile(c.FilePath, int64(c.FileMaxSizeMB)<<20, c.FileMaxBackups)
+	if err != nil {
+		return nil, err
+	}
+	exp, err := stdouttrace.New(stdouttrace.WithWriter(f))
+	if err != nil {
+		_ = f.Close()
+		return nil, err
+	}
+	return &fileExporter{Exporter: exp, file: f}, nil
+}
+
+// fileExporter closes the file when the exporter is shut down.
+type fileExporter struct {
+	*stdouttrace.Exporter
+	file *rotatingFile
+}
+
+func (e *fileExporter) Shutdown(ctx context.Context) error {
+	var err error
+	if shutdownErr := e.Exporter.Shutdown(ctx); shutdownErr != nil {
+		err = multierror.Append(err, shutdownErr)
+	}
+	if closeErr := e.file.Close(); closeErr != nil {
+		err = multierror.Append(err, fmt.Errorf("could not close span file: %w", closeErr))
+	}
+	return err
+}
+
+// rotatingFile is an io.Writer appending to a file. Once the file would
+// exceed maxSize it's renamed to path.1, path.1 to path.2 and so on,
+// keeping up to maxBackups old files. A maxSize of zero disables the rotation.
+type rotatingFile struct {
+	path       string
+	maxSize    int64
+	maxBackups int
+
+	mu   sync.Mutex
+	file *os.File
+	size int64
+}
+
+func newRotatingFile(path string, maxSize int64, maxBackups int) (*rotatingFile, error) {
+	f := &rotatingFile{
+		path:       path,
+		maxSize:    maxSize,
+		maxBackups: maxBackups,
+	}
+	if err := f.open(); err != nil {
+		return nil, err
+	}
+	return f, nil
+}
+
+func (f *rotatingFile) open() error {
+	file, err := os.OpenFile(f.path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o644)
+	if err != nil {
+		return fmt.Errorf("could not open span file: %w", err)
+	}
+	info, err := file.Stat()
+	if err != nil {
+		_ = file.Close()
+		return fmt.Errorf("could not open span file: %w", err)
+	}
+	f.file = file
+	f.size = info.Size()
+	return nil
+}
+
+func (f *rotatingFile) Write(p []byte) (int, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if f.file == nil {
+		return 0, os.ErrClosed
+	}
+	// A single write exceeding maxSize is written to an empty file
+	if f.maxSize > 0 && f.size > 0 && f.size+int64(len(p)) > f.maxSize {
+		if err := f.rotate(); err != nil {
+			return 0, err
+		}
+	}
+	n, err := f.file.Write(p)
+	f.size += int64(n)
+	return n, err
+}
+
+func (f *rotatingFile) rotate() error {
+	if err := f.file.Close(); err != nil {
+		return err
+	}
+	f.file = nil
+
+	if f.maxBackups > 0 {
+		for i := f.maxBackups - 1; i > 0; i-- {
+			err := os.Rename(f.backupPath(i), f.backupPath(i+1))
+			if err != nil && !os.IsNotExist(err) {
+				return fmt.Errorf("could not rotate span file: %w", err)
+			}
+		}
+		if err := os.Rename(f.path, f.backupPath(1)); err != nil {
+			return fmt.Errorf("could not rotate span file: %w", err)
+		}
+	} else if err := os.Remove(f.path); err != nil {
+		return fmt.Errorf("could not rotate span file: %w", err)
+	}
+
+	return f.open()
+}
+
+func (f *rotatingFile) backupPath(i int) string {
+	return fmt.Sprintf("%s.%d", f.path, i)
+}
+
+// Close syncs the file to disk and closes it.
+func (f *rotatingFile) Close() error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if f.file == nil {
+		return nil
+	}
+	err := f.file.Sync()
+	if closeErr := f.file.Close(); err == nil {
+		err = closeErr
+	}
+	f.file = nil
+	return err
+}
@@ -0,0 +1,100 @@
+package trace
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+)
+
+func TestFileExporter(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "spans.jsonl")
+
+	tp, err := StartAgent(zap.NewNop(), Config{
+		Name:     "foo",
+		Batcher:  kindFile,
+		FilePath: path,
+	})
+	require.NoError(t, err)
+
+	for _, name := range []string{"a", "b"} {
+		_, span := tp.Tracer(TraceName).Start(context.Background(), name)
+		span.End()
+	}
+	// Shutting down flushes the batched spans to the file
+	require.NoError(t, ShutdownAgent(context.Background(), tp))
+
+	f, err := os.Open(path)
+	require.NoError(t, err)
+	defer f.Close()
+
+	var names []string
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		var span struct{ Name string }
+		require.NoError(t, json.Unmarshal(scanner.Bytes(), &span))
+		names = append(names, span.Name)
+	}
+	require.NoError(t, scanner.Err())
+	assert.Equal(t, []string{"a", "b"}, names)
+}
+
+func TestFileExporterConfig(t *testing.T) {
+	err := Config{Batcher: kindFile}.Validate()
+	assert.ErrorContains(t, err, "missing file path for exporter file")
+
+	_, err = createExporter(context.Background(), Config{
+		Batcher:  kindFile,
+		FilePath: filepath.Join(t.TempDir(), "missing", "spans.jsonl"),
+	})
+	assert.ErrorContains(t, err, "could not open span file")
+}
+
+func TestRotatingFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "spans.jsonl")
+
+	f, err := newRotatingFile(path, 4, 2)
+	require.NoError(t, err)
+	for _, line := range []string{"a\n", "b\n", "c\n", "d\n", "e\n", "f\n", "g\n"} {
+		_, err := f.Write([]byte(line))
+		require.NoError(t, err)
+	}
+	require.NoError(t, f.Close())
+
+	_, err = f.Write([]byte("h\n"))
+	assert.ErrorIs(t, err, os.ErrClosed)
+
+	for p, expected := range map[string]string{
+		path:        "g\n",
+		path + ".1": "e\nf\n",
+		path + ".2": "c\nd\n",
+	} {
+		data, err := os.ReadFile(p)
+		require.NoError(t, err)
+		assert.Equal(t, expected, string(data), p)
+	}
+	assert.NoFileExists(t, path+".3")
+
+	t.Run("no backups", func(t *testing.T) {
+		path := filepath.Join(t.TempDir(), "spans.jsonl")
+
+		f, err := newRotatingFile(path, 4, 0)
+		require.NoError(t, err)
+		for _, line := range []string{"a\n", "b\n", "c\n"} {
+			_, err := f.Write([]byte(line))
+			require.NoError(t, err)
+		}
+		require.NoError(t, f.Close())
+
+		data, err := os.ReadFile(path)
+		require.NoError(t, err)
+		assert.Equal(t, "c\n", string(data))
+		assert.NoFileExists(t, path+".1")
+	})
+}
@@ -0,0 +1,127 @@
+package trace
+
+import (
+	"context"
+	"encoding/base64"
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+
+	"golang.org/x/net/http/httpguts"
+)
+
+// headerTransport is a http.RoundTripper which sets the given headers
+// and the headers of provider on every outgoing request. It's used for
+// exporters which don't support custom headers natively.
+type headerTransport struct {
+	rt       http.RoundTripper
+	headers  map[string]string
+	provider func() map[string]string
+}
+
+func (t *headerTransport) RoundTrip(r *http.Request) (*http.Response, error) {
+	// RoundTrip must not modify the original request
+	r = r.Clone(r.Context())
+	for k, v := range t.headers {
+		r.Header.Set(k, v)
+	}
+	if t.provider != nil {
+		for k, v := range t.provider() {
+			r.Header.Set(k, v)
+		}
+	}
+	return t.rt.RoundTrip(r)
+}
+
+// headerCredentials sends the headers of provider as metadata of every gRPC call.
+type headerCredentials struct {
+	provider func() map[string]string
+}
+
+func (c headerCredentials) GetRequestMetadata(ctx context.Context, uri ...string) (map[string]string, error) {
+	return c.provider(), nil
+}
+
+// RequireTransportSecurity returns false as the static headers
+// are sent over insecure connections, too.
+func (c headerCredentials) RequireTransportSecurity() bool {
+	return false
+}
+
+// ParseHeaders parses headers in the format of OTEL_EXPORTER_OTLP_HEADERS,
+// a comma separated list of key=value pairs with percent encoded values,
+// e.g. "Authorization=Bearer%20token,x-tenant=foo". Spaces around keys and
+// values and double quotes around values are removed. The keys must be valid
+// header names, the value of a key given more than once is the last one.
+func ParseHeaders(s string) (map[string]string, error) {
+	headers, err := parseKeyValues(s, "header", nil)
+	if err != nil {
+		return nil, err
+	}
+	keys := make([]string, 0, len(headers))
+	for key := range headers {
+		keys = append(keys, key)
+	}
+	// Report the first invalid header consistently
+	sort.Strings(keys)
+	for _, key := range keys {
+		value := headers[key]
+		if !httpguts.ValidHeaderFieldName(key) {
+			return nil, fmt.Errorf("malformed header: invalid name %q", key)
+		}
+		if len(value) >= 2 && value[0] == '"' && value[len(value)-1] == '"' {
+			value = value[1 : len(value)-1]
+		}
+		if !httpguts.ValidHeaderFieldValue(value) {
+			return nil, fmt.Errorf("malformed header value for %s: invalid characters", key)
+		}
+		headers[key] = value
+	}
+	return headers, nil
+}
+
+// ParseOtlpHeaders parses headers in the format of OTEL_EXPORTER_OTLP_HEADERS.
+//
+// Deprecated: Use ParseHeaders.
+func ParseOtlpHeaders(s string) (map[string]string, error) {
+	return ParseHeaders(s)
+}
+
+// otlpHeaders returns Config.OtlpHeadersRaw merged with Config.OtlpHeaders
+// and the basic auth header. Headers of Config.OtlpHeaders take precedence,
+// the basic auth header is only added if there is no Authorization header.
+func otlpHeaders(c Config) (map[string]string, error) {
+	basicAuth := c.BasicAuthUsername != "" && c.BasicAuthPassword != ""
+	if c.OtlpHeadersRaw == "" && !basicAuth {
+		return c.OtlpHeaders, nil
+	}
+
+	headers := make(map[string]string)
+	if c.OtlpHeadersRaw != "" {
+		raw, err := ParseHeaders(c.OtlpHeadersRaw)
+		if err != nil {
+			return nil, err
+		}
+		headers = raw
+	}
+	for k, v := range c.OtlpHeaders {
+		headers[k] = v
+	}
+
+	if basicAuth && !hasHeader(headers, "Authorization") {
+		credentials := base64.StdEncoding.EncodeToString([]byte(c.BasicAuthUsername + ":" + c.BasicAuthPassword))
+		headers["Authorization"] = "Basic " + credentials
+	}
+	return headers, nil
+}
+
+// hasHeader reports whether headers contains the key, ignoring the case.
+func hasHeader(headers map[string]string, key string) bool {
+	for k := range headers {
+		if strings.EqualFold(k, key) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,216 @@
+package trace
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"sync/atomic"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+)
+
+func TestHeaderTransport(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "Bearer token", r.Header.Get("Authorization"))
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer ts.Close()
+
+	c := http.Client{Transport: &headerTransport{
+		rt: http.DefaultTransport,
+		headers: map[string]string{
+			"Authorization": "Bearer token",
+		},
+	}}
+
+	r, err := http.NewRequest(http.MethodGet, ts.URL, nil)
+	require.NoError(t, err)
+
+	res, err := c.Do(r)
+	require.NoError(t, err)
+	defer res.Body.Close()
+
+	assert.Equal(t, http.StatusOK, res.StatusCode)
+	assert.Empty(t, r.Header.Get("Authorization"), "original request must not be modified")
+}
+
+func TestParseHeaders(t *testing.T) {
+	headers, err := ParseHeaders("a=1,,b=x%2Cy")
+	require.NoError(t, err)
+	assert.Equal(t, map[string]string{"a": "1", "b": "x,y"}, headers)
+
+	tests := []struct {
+		name     string
+		s        string
+		expected map[string]string
+	}{
+		{name: "spaces", s: " Authorization = Bearer%20token , x-tenant=foo ", expected: map[string]string{"Authorization": "Bearer token", "x-tenant": "foo"}},
+		{name: "quoted", s: `Authorization="Bearer token",x-tenant=""`, expected: map[string]string{"Authorization": "Bearer token", "x-tenant": ""}},
+		{name: "equal sign in value", s: "Authorization=Basic dXNlcjpwYXNz==", expected: map[string]string{"Authorization": "Basic dXNlcjpwYXNz=="}},
+		{name: "duplicate keys", s: "a=1,b=2,a=3", expected: map[string]string{"a": "3", "b": "2"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			headers, err := ParseHeaders(tt.s)
+			require.NoError(t, err)
+			assert.Equal(t, tt.expected, headers)
+		})
+	}
+
+	for s, msg := range map[string]string{
+		"a":         `malformed header: "a"`,
+		"=1":        `malformed header: "=1"`,
+		" =1":       `malformed header: " =1"`,
+		"a=%zz":     `malformed header value for a: invalid URL escape "%zz"`,
+		"a b=1":     `malformed header: invalid name "a b"`,
+		"a=x%0Ay":   "malformed header value for a: invalid characters",
+		"a=1,b:c=2": `malformed header: invalid name "b:c"`,
+	} {
+		_, err := ParseHeaders(s)
+		assert.EqualError(t, err, msg, s)
+	}
+}
+
+func TestParseOtlpHeaders(t *testing.T) {
+	headers, err := ParseOtlpHeaders(`a=1,b="x y"`)
+	require.NoError(t, err)
+	assert.Equal(t, map[string]string{"a": "1", "b": "x y"}, headers)
+}
+
+func TestOtlpHeaders(t *testing.T) {
+	headers, err := otlpHeaders(Config{OtlpHeaders: map[string]string{"a": "1"}})
+	require.NoError(t, err)
+	assert.Equal(t, map[string]string{"a": "1"}, headers)
+
+	headers, err = otlpHeaders(Config{
+		OtlpHeaders:    map[string]string{"a": "1"},
+		OtlpHeadersRaw: "a=2,b=3",
+	})
+	require.NoError(t, err)
+	assert.Equal(t, map[string]string{"a": "1", "b": "3"}, headers)
+
+	_, err = otlpHeaders(Config{OtlpHeadersRaw: "a"})
+	assert.EqualError(t, err, `malformed header: "a"`)
+}
+
+func TestZipkinHTTPClient(t *testing.T) {
+	ts := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "Bearer token", r.Header.Get("Authorization"))
+		w.WriteHeader(http.StatusAccepted)
+	}))
+	defer ts.Close()
+
+	client, err := exporterHTTPClient(Config{
+		HTTPClient: ts.Client(),
+		OtlpHeaders: map[string]string{
+			"Authorization": "Bearer token",
+		},
+	})
+	require.NoError(t, err)
+
+	res, err := client.Post(ts.URL, "application/json", nil)
+	require.NoError(t, err)
+	defer res.Body.Close()
+	assert.Equal(t, http.StatusAccepted, res.StatusCode)
+
+	// The client of the config is not modified
+	assert.IsType(t, &http.Transport{}, ts.Client().Transport)
+}
+
+func TestOtlpHeadersBasicAuth(t *testing.T) {
+	headers, err := otlpHeaders(Config{
+		BasicAuthUsername: "user",
+		BasicAuthPassword: "pass",
+		OtlpHeaders:       map[string]string{"x-tenant": "foo"},
+	})
+	require.NoError(t, err)
+	assert.Equal(t, map[string]string{
+		"x-tenant":      "foo",
+		"Authorization": "Basic dXNlcjpwYXNz",
+	}, headers)
+
+	for _, c := range []Config{
+		{OtlpHeaders: map[string]string{"authorization": "Bearer token"}},
+		{OtlpHeadersRaw: "Authorization=Bearer%20token"},
+	} {
+		c.BasicAuthUsername = "user"
+		c.BasicAuthPassword = "pass"
+		headers, err := otlpHeaders(c)
+		require.NoError(t, err)
+		assert.Len(t, headers, 1)
+		for _, v := range headers {
+			assert.Equal(t, "Bearer token", v)
+		}
+	}
+
+	err = Config{BasicAuthUsername: "user"}.Validate()
+	assert.ErrorContains(t, err, "basic auth requires both username and password")
+}
+
+func TestHeaderProvider(t *testing.T) {
+	var token atomic.Int32
+	provider := func() map[string]string {
+		return map[string]string{"Authorization": fmt.Sprintf("Bearer %d", token.Add(1))}
+	}
+
+	for _, batcher := range []string{kindOtlpHttp, kindZipkin} {
+		t.Run(batcher, func(t *testing.T) {
+			token.Store(0)
+			var (
+				mu             sync.Mutex
+				authorizations []string
+			)
+			ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				mu.Lock()
+				defer mu.Unlock()
+				authorizations = append(authorizations, r.Header.Get("Authorization"))
+				assert.Equal(t, "foo", r.Header.Get("x-tenant"))
+				w.WriteHeader(http.StatusAccepted)
+			}))
+			defer ts.Close()
+
+			tp, err := StartAgent(zap.NewNop(), Config{
+				Name:     "foo",
+				Endpoint: ts.URL + "/api/v2/spans",
+				Batcher:  batcher,
+				Sampler:  1,
+				OtlpHeaders: map[string]string{
+					"Authorization": "Bearer static",
+					"x-tenant":      "foo",
+				},
+				HeaderProvider: provider,
+			})
+			require.NoError(t, err)
+
+			for i := 0; i < 2; i++ {
+				_, span := tp.Tracer(TraceName).Start(context.Background(), "span")
+				span.End()
+				require.NoError(t, tp.ForceFlush(context.Background()))
+			}
+			require.NoError(t, ShutdownAgent(context.Background(), tp))
+
+			mu.Lock()
+			defer mu.Unlock()
+			assert.Equal(t, []string{"Bearer 1", "Bearer 2"}, authorizations)
+		})
+	}
+
+	t.Run(kindOtlpGrpc, func(t *testing.T) {
+		token.Store(0)
+		md, err := headerCredentials{provider: provider}.GetRequestMetadata(context.Background())
+		require.NoError(t, err)
+		assert.Equal(t, map[string]string{"Authorization": "Bearer 1"}, md)
+
+		_, err = createExporter(context.Background(), Config{
+			Endpoint:       "http://localhost:4317",
+			Batcher:        kindOtlpGrpc,
+			HeaderProvider: provider,
+		})
+		assert.NoError(t, err)
+	})
+}
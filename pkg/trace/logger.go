@@ -0,0 +1,37 @@
+package trace
+
+import (
+	"context"
+
+	"go.opentelemetry.io/otel/trace"
+	"go.uber.org/zap"
+)
+
+// TraceFields returns the trace_id, span_id and trace_sampled fields of the
+// span in ctx, or no fields without a valid span. Request scoped loggers
+// can be created with log.With(TraceFields(ctx)...), see also LoggerWithSpan.
+func TraceFields(ctx context.Context) []zap.Field {
+	sc := trace.SpanContextFromContext(ctx)
+	if !sc.IsValid() {
+		return []zap.Field{}
+	}
+	return []zap.Field{
+		zap.String("trace_id", sc.TraceID().String()),
+		zap.String("span_id", sc.SpanID().String()),
+		zap.Bool("trace_sampled", sc.IsSampled()),
+	}
+}
+
+// LoggerWithSpan returns a logger with the trace_id and span_id fields of the
+// span in ctx, so log lines can be correlated with the trace. Without a valid
+// span, log is returned as is.
+func LoggerWithSpan(ctx context.Context, log *zap.Logger) *zap.Logger {
+	sc := trace.SpanContextFromContext(ctx)
+	if !sc.IsValid() {
+		return log
+	}
+	return log.With(
+		zap.String("trace_id", sc.TraceID().String()),
+		zap.String("span_id", sc.SpanID().String()),
+	)
+}
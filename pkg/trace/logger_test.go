@@ -0,0 +1,53 @@
+package trace
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/otel/trace"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zaptest/observer"
+)
+
+func TestLoggerWithSpan(t *testing.T) {
+	core, logs := observer.New(zap.InfoLevel)
+	log := zap.New(core)
+
+	assert.Same(t, log, LoggerWithSpan(context.Background(), log))
+
+	ctx := trace.ContextWithSpanContext(context.Background(), trace.NewSpanContext(trace.SpanContextConfig{
+		TraceID: traceID,
+		SpanID:  spanID,
+	}))
+	LoggerWithSpan(ctx, log).Info("message")
+
+	entries := logs.All()
+	require.Len(t, entries, 1)
+	assert.Equal(t, map[string]interface{}{
+		"trace_id": traceIDStr,
+		"span_id":  spanID.String(),
+	}, entries[0].ContextMap())
+}
+
+func TestTraceFields(t *testing.T) {
+	assert.Empty(t, TraceFields(context.Background()))
+
+	ctx := trace.ContextWithSpanContext(context.Background(), trace.NewSpanContext(trace.SpanContextConfig{
+		TraceID:    traceID,
+		SpanID:     spanID,
+		TraceFlags: trace.FlagsSampled,
+	}))
+
+	core, logs := observer.New(zap.InfoLevel)
+	zap.New(core).With(TraceFields(ctx)...).Info("message")
+
+	entries := logs.All()
+	require.Len(t, entries, 1)
+	assert.Equal(t, map[string]interface{}{
+		"trace_id":      traceIDStr,
+		"span_id":       spanID.String(),
+		"trace_sampled": true,
+	}, entries[0].ContextMap())
+}
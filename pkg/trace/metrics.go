@@ -0,0 +1,152 @@
+package trace
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/hashicorp/go-multierror"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/exporters/otlp/otlpmetric/otlpmetrichttp"
+	sdkmetric "go.opentelemetry.io/otel/sdk/metric"
+	"go.uber.org/zap"
+)
+
+// defaultOtlpMetricsPath is the default path of the OTLP HTTP metrics receiver
+const defaultOtlpMetricsPath = "/v1/metrics"
+
+// StartMetricsAgent starts an OpenTelemetry meter provider exporting the metrics
+// with OTLP over HTTP to the host of Config.Endpoint every MetricsExportInterval.
+// It shares the headers, TLS, compression, timeout, retry and resource settings
+// with the trace agent, the path defaults to /v1/metrics. ProxyURL, HTTPClient and
+// HeaderProvider don't apply, the exporter uses the proxy environment variables.
+// Batcher must be empty or an exporter sending with OTLP HTTP.
+// The provider is installed as global meter provider unless SetGlobal is false.
+// When Disabled is set, the provider doesn't export the metrics.
+func StartMetricsAgent(log *zap.Logger, c Config) (*sdkmetric.MeterProvider, error) {
+	if c.Disabled {
+		provider := sdkmetric.NewMeterProvider()
+		installMeterProvider(c, provider)
+		return provider, nil
+	}
+
+	if c.Endpoint == "" {
+		err := fmt.Errorf("%w: missing endpoint of the metrics exporter", ErrInvalidEndpoint)
+		log.Error("invalid metrics config", zap.Error(err))
+		return nil, err
+	}
+	if err := validateMetricsConfig(c); err != nil {
+		log.Error("invalid metrics config", zap.Error(err))
+		return nil, err
+	}
+	if len(c.ProxyURL) > 0 || c.HTTPClient != nil || c.HeaderProvider != nil {
+		log.Warn("ProxyURL, HTTPClient and HeaderProvider don't apply to the metrics exporter")
+	}
+	headers, err := otlpHeaders(c)
+	if err != nil {
+		log.Error("invalid OpenTelemetry headers", zap.Error(err))
+		return nil, err
+	}
+	c.OtlpHeaders = headers
+
+	exp, err := createMetricsExporter(context.Background(), c)
+	if err != nil {
+		log.Error("create metrics exporter error", zap.Error(err))
+		return nil, err
+	}
+
+	var readerOpts []sdkmetric.PeriodicReaderOption
+	if c.MetricsExportInterval > 0 {
+		readerOpts = append(readerOpts, sdkmetric.WithInterval(c.MetricsExportInterval))
+	}
+	if c.ExportTimeout > 0 {
+		readerOpts = append(readerOpts, sdkmetric.WithTimeout(c.ExportTimeout))
+	}
+
+	provider := sdkmetric.NewMeterProvider(
+		sdkmetric.WithResource(createResource(log, c)),
+		sdkmetric.WithReader(sdkmetric.NewPeriodicReader(exp, readerOpts...)),
+	)
+	installMeterProvider(c, provider)
+
+	log.Debug("metrics started",
+		zap.String("service_name", ServiceName(c)),
+		zap.String("endpoint", c.Endpoint),
+		zap.Duration("export_interval", c.MetricsExportInterval),
+	)
+	return provider, nil
+}
+
+// validateMetricsConfig validates the settings of c used by the metrics exporter.
+// The metrics are always exported with OTLP HTTP, so only the batchers exporting
+// with it are accepted. The trace only settings are left to Config.Validate.
+func validateMetricsConfig(c Config) error {
+	var err error
+	switch c.Batcher {
+	case "", kindOtlpHttp, kindJaeger:
+	default:
+		err = multierror.Append(err, fmt.Errorf("%w: the metrics can't be exported with %s, only with %s", ErrUnknownExporter, c.Batcher, kindOtlpHttp))
+	}
+	headers, headersErr := otlpHeaders(c)
+	if headersErr != nil {
+		err = multierror.Append(err, fmt.Errorf("invalid OpenTelemetry headers: %w", headersErr))
+		headers = c.OtlpHeaders
+	}
+	err = validateExporter(err, kindOtlpHttp, c.Endpoint, tlsConfigured(c), headers)
+	err = validateBasicAuth(err, c)
+	return validateDetectors(err, c.Detectors)
+}
+
+func createMetricsExporter(ctx context.Context, c Config) (sdkmetric.Exporter, error) {
+	u, insecure, err := parseEndpoint(c)
+	if err != nil {
+		return nil, err
+	}
+	tlsConfig, err := createTLSConfig(c)
+	if err != nil {
+		return nil, err
+	}
+
+	path := defaultOtlpMetricsPath
+	if len(c.MetricsPath) > 0 {
+		path = c.MetricsPath
+	}
+
+	opts := []otlpmetrichttp.Option{
+		// Includes host and port
+		otlpmetrichttp.WithEndpoint(u.Host),
+		otlpmetrichttp.WithURLPath(path),
+		otlpmetrichttp.WithTimeout(otlpHttpTimeout(c)),
+	}
+	if tlsConfig != nil {
+		opts = append(opts, otlpmetrichttp.WithTLSClientConfig(tlsConfig))
+	} else if insecure {
+		opts = append(opts, otlpmetrichttp.WithInsecure())
+	}
+	if len(c.OtlpHeaders) > 0 {
+		opts = append(opts, otlpmetrichttp.WithHeaders(c.OtlpHeaders))
+	}
+	if c.RetryConfig != nil {
+		rc := otlpHttpRetryConfig(c.RetryConfig)
+		opts = append(opts, otlpmetrichttp.WithRetry(otlpmetrichttp.RetryConfig{
+			Enabled:         rc.Enabled,
+			InitialInterval: rc.InitialInterval,
+			MaxInterval:     rc.MaxInterval,
+			MaxElapsedTime:  rc.MaxElapsedTime,
+		}))
+	}
+	switch c.Compression {
+	case "", compressionNone:
+	case compressionGzip:
+		opts = append(opts, otlpmetrichttp.WithCompression(otlpmetrichttp.GzipCompression))
+	default:
+		return nil, fmt.Errorf("unknown compression: %s", c.Compression)
+	}
+	return otlpmetrichttp.New(ctx, opts...)
+}
+
+func installMeterProvider(c Config, provider *sdkmetric.MeterProvider) {
+	if !setGlobal(c) {
+		return
+	}
+	otel.SetMeterProvider(provider)
+}
@@ -0,0 +1,132 @@
+package trace
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+)
+
+func TestStartMetricsAgent(t *testing.T) {
+	var (
+		mu       sync.Mutex
+		requests []string
+	)
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		defer mu.Unlock()
+		requests = append(requests, r.Method+" "+r.URL.Path+" "+r.Header.Get("Authorization"))
+	}))
+	defer ts.Close()
+
+	setGlobal := false
+	tests := []struct {
+		name     string
+		path     string
+		expected string
+	}{
+		{name: "default path", expected: "POST /v1/metrics Bearer token"},
+		{name: "custom path", path: "/custom/v1/metrics", expected: "POST /custom/v1/metrics Bearer token"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mu.Lock()
+			requests = nil
+			mu.Unlock()
+
+			mp, err := StartMetricsAgent(zap.NewNop(), Config{
+				Name:           "foo",
+				Endpoint:       ts.URL,
+				MetricsPath:    tt.path,
+				OtlpHeadersRaw: "Authorization=Bearer%20token",
+				SetGlobal:      &setGlobal,
+			})
+			require.NoError(t, err)
+
+			counter, err := mp.Meter(TraceName).Int64Counter("requests")
+			require.NoError(t, err)
+			counter.Add(context.Background(), 1)
+
+			// Shutting down exports the pending metrics
+			require.NoError(t, mp.Shutdown(context.Background()))
+
+			mu.Lock()
+			defer mu.Unlock()
+			assert.Equal(t, []string{tt.expected}, requests)
+		})
+	}
+
+	t.Run("missing endpoint", func(t *testing.T) {
+		_, err := StartMetricsAgent(zap.NewNop(), Config{Name: "foo", SetGlobal: &setGlobal})
+		assert.ErrorIs(t, err, ErrInvalidEndpoint)
+	})
+
+	t.Run("invalid config", func(t *testing.T) {
+		_, err := StartMetricsAgent(zap.NewNop(), Config{Endpoint: ts.URL, BasicAuthUsername: "user", SetGlobal: &setGlobal})
+		assert.ErrorContains(t, err, "basic auth requires both username and password")
+	})
+
+	t.Run("batcher", func(t *testing.T) {
+		for _, batcher := range []string{kindOtlpGrpc, kindZipkin, kindStdout, kindFile} {
+			_, err := StartMetricsAgent(zap.NewNop(), Config{Endpoint: ts.URL, Batcher: batcher, SetGlobal: &setGlobal})
+			assert.ErrorIs(t, err, ErrUnknownExporter, batcher)
+		}
+		mp, err := StartMetricsAgent(zap.NewNop(), Config{Endpoint: ts.URL, Batcher: kindJaeger, SetGlobal: &setGlobal})
+		require.NoError(t, err)
+		require.NoError(t, mp.Shutdown(context.Background()))
+	})
+
+	t.Run("trace settings", func(t *testing.T) {
+		// Only the settings of the metrics exporter are validated
+		mp, err := StartMetricsAgent(zap.NewNop(), Config{
+			Endpoint:              ts.URL,
+			SamplerRules:          []SamplerRule{{AttributeValue: "/health"}},
+			ErrorExporterEndpoint: "http:///v1/traces",
+			Exporters:             []ExporterConfig{{Batcher: "otlp"}},
+			RedactAttributeKeys:   []string{"["},
+			SetGlobal:             &setGlobal,
+		})
+		require.NoError(t, err)
+		require.NoError(t, mp.Shutdown(context.Background()))
+	})
+
+	t.Run("retry", func(t *testing.T) {
+		var attempts atomic.Int32
+		ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if attempts.Add(1) == 1 {
+				w.WriteHeader(http.StatusServiceUnavailable)
+			}
+		}))
+		defer ts.Close()
+
+		mp, err := StartMetricsAgent(zap.NewNop(), Config{
+			Endpoint:    ts.URL,
+			RetryConfig: &RetryConfig{Enabled: true, InitialInterval: time.Millisecond},
+			SetGlobal:   &setGlobal,
+		})
+		require.NoError(t, err)
+		counter, err := mp.Meter(TraceName).Int64Counter("requests")
+		require.NoError(t, err)
+		counter.Add(context.Background(), 1)
+		require.NoError(t, mp.Shutdown(context.Background()))
+		assert.Equal(t, int32(2), attempts.Load())
+	})
+
+	t.Run("unknown compression", func(t *testing.T) {
+		_, err := StartMetricsAgent(zap.NewNop(), Config{Endpoint: ts.URL, Compression: "zstd", SetGlobal: &setGlobal})
+		assert.EqualError(t, err, "unknown compression: zstd")
+	})
+
+	t.Run("disabled", func(t *testing.T) {
+		mp, err := StartMetricsAgent(zap.NewNop(), Config{Disabled: true, SetGlobal: &setGlobal})
+		require.NoError(t, err)
+		assert.NoError(t, mp.Shutdown(context.Background()))
+	})
+}
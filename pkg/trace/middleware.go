@@ -0,0 +1,48 @@
+package trace
+
+import (
+	"net/http"
+
+	"go.opentelemetry.io/contrib/instrumentation/net/http/otelhttp"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// Middleware wraps a http.Handler and starts a server span for every request.
+// The span continues the trace of the incoming request using the global
+// propagator and is created with the global provider installed by StartAgent.
+// It records the method, target and status code, the span duration is the latency
+// of the request. Responses with a status code >= 500 set the span status to error.
+func Middleware(next http.Handler, opts ...otelhttp.Option) http.Handler {
+	// Don't trace health check requests or favicon browser requests
+	opts = append([]otelhttp.Option{
+		otelhttp.WithFilter(RequestFilter),
+		otelhttp.WithSpanNameFormatter(SpanNameFormatter),
+	}, opts...)
+
+	return otelhttp.NewHandler(next, "", opts...)
+}
+
+// WithTraceIDHeader returns a middleware writing the trace ID of the active span
+// into the response header name, e.g. X-Trace-Id, so users can refer to the trace
+// in bug reports. It must wrap the handler passed to Middleware or WrapHandler:
+//
+//	Middleware(WithTraceIDHeader("X-Trace-Id", false)(handler))
+//
+// The header is only written for sampled spans, unless recording is set, then
+// it's also written for spans which are recorded but not sampled.
+// No header is written if name is empty.
+func WithTraceIDHeader(name string, recording bool) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		if name == "" {
+			return next
+		}
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			span := trace.SpanFromContext(r.Context())
+			sc := span.SpanContext()
+			if sc.IsSampled() || (recording && span.IsRecording()) {
+				w.Header().Set(name, sc.TraceID().String())
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
@@ -0,0 +1,123 @@
+package trace
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/contrib/instrumentation/net/http/otelhttp"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/propagation"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	semconv17 "go.opentelemetry.io/otel/semconv/v1.17.0"
+	"go.opentelemetry.io/otel/trace"
+
+	"github.com/wundergraph/wundergraph/pkg/trace/tracetest"
+)
+
+func TestMiddleware(t *testing.T) {
+	exporter := tracetest.NewInMemoryExporter(t)
+
+	h := Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/fail" {
+			w.WriteHeader(http.StatusBadGateway)
+			return
+		}
+		w.WriteHeader(http.StatusOK)
+	}))
+
+	t.Run("continues the incoming trace", func(t *testing.T) {
+		exporter.Reset()
+
+		req := httptest.NewRequest(http.MethodGet, "/test", nil)
+		parent := trace.ContextWithRemoteSpanContext(context.Background(), trace.NewSpanContext(trace.SpanContextConfig{
+			TraceID:    traceID,
+			SpanID:     spanID,
+			TraceFlags: trace.FlagsSampled,
+			Remote:     true,
+		}))
+		otel.GetTextMapPropagator().Inject(parent, propagation.HeaderCarrier(req.Header))
+
+		h.ServeHTTP(httptest.NewRecorder(), req)
+
+		spans := exporter.GetSpans().Snapshots()
+		require.Len(t, spans, 1)
+		assert.Equal(t, "GET /test", spans[0].Name())
+		assert.Equal(t, trace.SpanKindServer, spans[0].SpanKind())
+		assert.Equal(t, traceID, spans[0].SpanContext().TraceID())
+		assert.Equal(t, spanID, spans[0].Parent().SpanID())
+		assert.Contains(t, spans[0].Attributes(), semconv17.HTTPStatusCode(http.StatusOK))
+		assert.Equal(t, codes.Unset, spans[0].Status().Code)
+	})
+
+	t.Run("server errors", func(t *testing.T) {
+		exporter.Reset()
+
+		h.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodPost, "/fail", nil))
+
+		spans := exporter.GetSpans().Snapshots()
+		require.Len(t, spans, 1)
+		assert.Equal(t, "POST /fail", spans[0].Name())
+		assert.Contains(t, spans[0].Attributes(), semconv17.HTTPStatusCode(http.StatusBadGateway))
+		assert.Equal(t, codes.Error, spans[0].Status().Code)
+	})
+
+	t.Run("health checks are not traced", func(t *testing.T) {
+		exporter.Reset()
+
+		h.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/health", nil))
+		assert.Empty(t, exporter.GetSpans())
+	})
+}
+
+func TestWithTraceIDHeader(t *testing.T) {
+	ok := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	})
+
+	tests := []struct {
+		name      string
+		header    string
+		recording bool
+		sampler   sdktrace.Sampler
+		expected  bool
+	}{
+		{name: "sampled", header: "X-Trace-Id", sampler: sdktrace.AlwaysSample(), expected: true},
+		{name: "not sampled", header: "X-Trace-Id", sampler: sdktrace.NeverSample()},
+		{name: "recorded", header: "X-Trace-Id", sampler: recordOnlySampler{}},
+		{name: "recorded with recording", header: "X-Trace-Id", recording: true, sampler: recordOnlySampler{}, expected: true},
+		{name: "empty name", sampler: sdktrace.AlwaysSample()},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tp := sdktrace.NewTracerProvider(sdktrace.WithSampler(tt.sampler))
+			h := Middleware(WithTraceIDHeader(tt.header, tt.recording)(ok), otelhttp.WithTracerProvider(tp))
+
+			rec := httptest.NewRecorder()
+			h.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/test", nil))
+
+			header := rec.Header().Get("X-Trace-Id")
+			if !tt.expected {
+				assert.Empty(t, header)
+				return
+			}
+			assert.Len(t, header, 32)
+			assert.NotEqual(t, trace.TraceID{}.String(), header)
+		})
+	}
+}
+
+// recordOnlySampler records but doesn't sample all spans.
+type recordOnlySampler struct{}
+
+func (recordOnlySampler) ShouldSample(p sdktrace.SamplingParameters) sdktrace.SamplingResult {
+	return sdktrace.SamplingResult{Decision: sdktrace.RecordOnly}
+}
+
+func (recordOnlySampler) Description() string {
+	return "RecordOnly"
+}
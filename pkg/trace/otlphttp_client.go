@@ -0,0 +1,165 @@
+package trace
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"time"
+
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
+	coltracepb "go.opentelemetry.io/proto/otlp/collector/trace/v1"
+	tracepb "go.opentelemetry.io/proto/otlp/trace/v1"
+	"google.golang.org/protobuf/proto"
+)
+
+const defaultOtlpHttpPath = "/v1/traces"
+
+// assert that otlpHttpClient implements the otlptrace.Client interface
+var _ otlptrace.Client = (*otlpHttpClient)(nil)
+
+// otlpHttpClient uploads spans with OTLP over HTTP using a user provided http.Client.
+// The otlptracehttp exporter doesn't allow to customize the http.Client.
+type otlpHttpClient struct {
+	client   *http.Client
+	url      string
+	headers  map[string]string
+	compress bool
+	// retry retries the failed uploads like the otlptracehttp exporter
+	retry otlptracehttp.RetryConfig
+}
+
+func (c *otlpHttpClient) Start(ctx context.Context) error {
+	return nil
+}
+
+func (c *otlpHttpClient) Stop(ctx context.Context) error {
+	c.client.CloseIdleConnections()
+	return nil
+}
+
+func (c *otlpHttpClient) UploadTraces(ctx context.Context, protoSpans []*tracepb.ResourceSpans) error {
+	body, err := proto.Marshal(&coltracepb.ExportTraceServiceRequest{
+		ResourceSpans: protoSpans,
+	})
+	if err != nil {
+		return err
+	}
+
+	if c.compress {
+		var buf bytes.Buffer
+		gz := gzip.NewWriter(&buf)
+		if _, err := gz.Write(body); err != nil {
+			return err
+		}
+		if err := gz.Close(); err != nil {
+			return err
+		}
+		body = buf.Bytes()
+	}
+
+	return c.withRetry(ctx, func() error {
+		return c.upload(ctx, body)
+	})
+}
+
+// retryableError is an upload error which is retried, after is the
+// minimum wait time requested by the Retry-After header.
+type retryableError struct {
+	err   error
+	after time.Duration
+}
+
+func (e *retryableError) Error() string {
+	return e.err.Error()
+}
+
+func (e *retryableError) Unwrap() error {
+	return e.err
+}
+
+// withRetry calls upload until it succeeds, fails with an error which isn't
+// retryable or the retry config gives up. The wait time between the attempts
+// doubles from InitialInterval up to MaxInterval.
+func (c *otlpHttpClient) withRetry(ctx context.Context, upload func() error) error {
+	if !c.retry.Enabled {
+		return upload()
+	}
+	start := time.Now()
+	interval := c.retry.InitialInterval
+	for {
+		err := upload()
+		var retryErr *retryableError
+		if !errors.As(err, &retryErr) {
+			return err
+		}
+
+		wait := interval
+		if retryErr.after > wait {
+			wait = retryErr.after
+		}
+		if time.Since(start)+wait > c.retry.MaxElapsedTime {
+			return retryErr.err
+		}
+		timer := time.NewTimer(wait)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return fmt.Errorf("%w: %w", ctx.Err(), retryErr.err)
+		case <-timer.C:
+		}
+
+		interval *= 2
+		if interval > c.retry.MaxInterval {
+			interval = c.retry.MaxInterval
+		}
+	}
+}
+
+func (c *otlpHttpClient) upload(ctx context.Context, body []byte) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, c.url, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	for k, v := range c.headers {
+		req.Header.Set(k, v)
+	}
+	req.Header.Set("Content-Type", "application/x-protobuf")
+	if c.compress {
+		req.Header.Set("Content-Encoding", "gzip")
+	}
+
+	res, err := c.client.Do(req)
+	if err != nil {
+		if ctx.Err() != nil {
+			return err
+		}
+		// Connection errors are temporary
+		return &retryableError{err: err}
+	}
+	defer res.Body.Close()
+
+	// Drain the body to allow the connection to be reused
+	_, _ = io.Copy(io.Discard, res.Body)
+
+	if res.StatusCode >= 200 && res.StatusCode <= 299 {
+		return nil
+	}
+	err = fmt.Errorf("failed to send spans to %s: %s", c.url, res.Status)
+	switch res.StatusCode {
+	// The status codes retried by the otlptracehttp exporter
+	case http.StatusTooManyRequests, http.StatusBadGateway, http.StatusServiceUnavailable, http.StatusGatewayTimeout:
+		var after time.Duration
+		if seconds, parseErr := strconv.Atoi(res.Header.Get("Retry-After")); parseErr == nil && seconds > 0 {
+			after = time.Duration(seconds) * time.Second
+		}
+		return &retryableError{err: err, after: after}
+	default:
+		return err
+	}
+}
@@ -0,0 +1,197 @@
+package trace
+
+import (
+	"compress/gzip"
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	coltracepb "go.opentelemetry.io/proto/otlp/collector/trace/v1"
+	"go.uber.org/zap"
+	"google.golang.org/protobuf/proto"
+)
+
+func TestOtlpHttpClient(t *testing.T) {
+	for _, compression := range []string{compressionNone, compressionGzip} {
+		t.Run(compression, func(t *testing.T) {
+			var received []*coltracepb.ExportTraceServiceRequest
+
+			ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				assert.Equal(t, "/v1/traces", r.URL.Path)
+				assert.Equal(t, "application/x-protobuf", r.Header.Get("Content-Type"))
+				assert.Equal(t, "Bearer token", r.Header.Get("Authorization"))
+
+				body := r.Body
+				if compression == compressionGzip {
+					assert.Equal(t, "gzip", r.Header.Get("Content-Encoding"))
+					gz, err := gzip.NewReader(r.Body)
+					require.NoError(t, err)
+					body = gz
+				}
+				data, err := io.ReadAll(body)
+				require.NoError(t, err)
+
+				var req coltracepb.ExportTraceServiceRequest
+				require.NoError(t, proto.Unmarshal(data, &req))
+				received = append(received, &req)
+			}))
+			defer ts.Close()
+
+			tp, err := StartAgent(zap.NewNop(), Config{
+				Name:        "foo",
+				Endpoint:    ts.URL,
+				Batcher:     kindOtlpHttp,
+				Sampler:     1,
+				Compression: compression,
+				HTTPClient:  ts.Client(),
+				OtlpHeaders: map[string]string{
+					"Authorization": "Bearer token",
+				},
+			})
+			require.NoError(t, err)
+
+			_, span := tp.Tracer(TraceName).Start(context.Background(), "span")
+			span.End()
+			require.NoError(t, ShutdownAgent(context.Background(), tp))
+
+			require.Len(t, received, 1)
+			spans := received[0].ResourceSpans[0].ScopeSpans[0].Spans
+			require.Len(t, spans, 1)
+			assert.Equal(t, "span", spans[0].Name)
+		})
+	}
+
+	t.Run("endpoint path", func(t *testing.T) {
+		tests := []struct {
+			name     string
+			endpoint string
+			path     string
+			expected string
+		}{
+			{name: "default", endpoint: "", expected: "/v1/traces"},
+			{name: "root", endpoint: "/", expected: "/v1/traces"},
+			{name: "endpoint", endpoint: "/custom/v1/traces", expected: "/custom/v1/traces"},
+			{name: "explicit", endpoint: "/custom/v1/traces", path: "/explicit", expected: "/explicit"},
+		}
+		for _, tt := range tests {
+			t.Run(tt.name, func(t *testing.T) {
+				var paths []string
+				ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+					paths = append(paths, r.URL.Path)
+				}))
+				defer ts.Close()
+
+				// Both the SDK and the custom client transport
+				for _, client := range []*http.Client{nil, ts.Client()} {
+					tp, err := StartAgent(zap.NewNop(), Config{
+						Name:         "foo",
+						Endpoint:     ts.URL + tt.endpoint,
+						Batcher:      kindOtlpHttp,
+						OtlpHttpPath: tt.path,
+						HTTPClient:   client,
+					})
+					require.NoError(t, err)
+
+					_, span := tp.Tracer(TraceName).Start(context.Background(), "span")
+					span.End()
+					require.NoError(t, ShutdownAgent(context.Background(), tp))
+				}
+
+				assert.Equal(t, []string{tt.expected, tt.expected}, paths)
+			})
+		}
+	})
+
+	t.Run("error status", func(t *testing.T) {
+		ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(http.StatusServiceUnavailable)
+		}))
+		defer ts.Close()
+
+		c := &otlpHttpClient{client: ts.Client(), url: ts.URL}
+		err := c.UploadTraces(context.Background(), nil)
+		assert.ErrorContains(t, err, "503 Service Unavailable")
+	})
+
+	t.Run("retry", func(t *testing.T) {
+		var (
+			mu       sync.Mutex
+			statuses = []int{http.StatusServiceUnavailable, http.StatusTooManyRequests, http.StatusOK}
+			attempts int
+		)
+		ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			mu.Lock()
+			defer mu.Unlock()
+			w.WriteHeader(statuses[attempts])
+			attempts++
+		}))
+		defer ts.Close()
+
+		retry := otlpHttpRetryConfig(&RetryConfig{Enabled: true, InitialInterval: time.Millisecond, MaxInterval: time.Millisecond, MaxElapsedTime: time.Second})
+		c := &otlpHttpClient{client: ts.Client(), url: ts.URL, retry: retry}
+		require.NoError(t, c.UploadTraces(context.Background(), nil))
+		mu.Lock()
+		assert.Equal(t, 3, attempts)
+		mu.Unlock()
+
+		// Client errors are not retried
+		mu.Lock()
+		statuses, attempts = []int{http.StatusBadRequest}, 0
+		mu.Unlock()
+		assert.ErrorContains(t, c.UploadTraces(context.Background(), nil), "400 Bad Request")
+
+		// Gives up after MaxElapsedTime
+		mu.Lock()
+		statuses, attempts = []int{http.StatusBadGateway, http.StatusBadGateway, http.StatusBadGateway}, 0
+		mu.Unlock()
+		c.retry.MaxElapsedTime = 50 * time.Millisecond
+		c.retry.InitialInterval, c.retry.MaxInterval = 30*time.Millisecond, 30*time.Millisecond
+		assert.ErrorContains(t, c.UploadTraces(context.Background(), nil), "502 Bad Gateway")
+		mu.Lock()
+		assert.Equal(t, 2, attempts)
+		mu.Unlock()
+	})
+
+	t.Run("retry config applies", func(t *testing.T) {
+		var attempts atomic.Int32
+		ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if attempts.Add(1) == 1 {
+				w.WriteHeader(http.StatusServiceUnavailable)
+			}
+		}))
+		defer ts.Close()
+
+		// The custom client is also used with a header provider
+		for _, retry := range []*RetryConfig{
+			{Enabled: true, InitialInterval: time.Millisecond},
+			{Enabled: false},
+		} {
+			attempts.Store(0)
+			tp, err := StartAgent(zap.NewNop(), Config{
+				Name:           "foo",
+				Endpoint:       ts.URL,
+				Batcher:        kindOtlpHttp,
+				SyncExport:     true,
+				HeaderProvider: func() map[string]string { return nil },
+				RetryConfig:    retry,
+			})
+			require.NoError(t, err)
+			_, span := tp.Tracer(TraceName).Start(context.Background(), "span")
+			span.End()
+			require.NoError(t, ShutdownAgent(context.Background(), tp))
+
+			expected := int32(1)
+			if retry.Enabled {
+				expected = 2
+			}
+			assert.Equal(t, expected, attempts.Load())
+		}
+	})
+}
@@ -0,0 +1,73 @@
+package trace
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"time"
+
+	"github.com/hashicorp/go-multierror"
+)
+
+// probeTimeout bounds the connectivity check of a single endpoint.
+const probeTimeout = 5 * time.Second
+
+// ProbeEndpoint checks that the endpoints of the exporters configured by c
+// are reachable. The OTLP gRPC endpoints are dialed, the others receive a HEAD
+// request and count as reachable on any response. All unreachable endpoints
+// are reported at once.
+func ProbeEndpoint(ctx context.Context, c Config) error {
+	var err error
+	for _, ec := range exporterConfigs(c) {
+		if !needsEndpoint(ec.Batcher) {
+			continue
+		}
+		if probeErr := probeExporter(ctx, ec); probeErr != nil {
+			err = multierror.Append(err, fmt.Errorf("probe %s endpoint %s: %w", ec.Batcher, ec.Endpoint, probeErr))
+		}
+	}
+	return err
+}
+
+func probeExporter(ctx context.Context, c Config) error {
+	u, insecure, err := parseEndpoint(c)
+	if err != nil {
+		return err
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, probeTimeout)
+	defer cancel()
+
+	if c.Batcher == kindOtlpGrpc {
+		var d net.Dialer
+		conn, err := d.DialContext(ctx, "tcp", u.Host)
+		if err != nil {
+			return err
+		}
+		return conn.Close()
+	}
+
+	target := *u
+	// The OTLP exporters only take the host of the endpoint
+	if c.Batcher != kindZipkin {
+		target = url.URL{Scheme: "https", Host: u.Host}
+		if insecure {
+			target.Scheme = "http"
+		}
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodHead, target.String(), nil)
+	if err != nil {
+		return err
+	}
+	client, err := exporterHTTPClient(c)
+	if err != nil {
+		return err
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	return resp.Body.Close()
+}
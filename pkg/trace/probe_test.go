@@ -0,0 +1,75 @@
+package trace
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+)
+
+// closedEndpoint returns the address of a port nothing listens on.
+func closedEndpoint(t *testing.T) string {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	addr := l.Addr().String()
+	require.NoError(t, l.Close())
+	return addr
+}
+
+func TestProbeEndpoint(t *testing.T) {
+	var (
+		mu      sync.Mutex
+		methods []string
+	)
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		defer mu.Unlock()
+		methods = append(methods, r.Method+" "+r.URL.Path)
+		w.WriteHeader(http.StatusMethodNotAllowed)
+	}))
+	defer ts.Close()
+
+	dead := "http://" + closedEndpoint(t)
+
+	t.Run("http", func(t *testing.T) {
+		require.NoError(t, ProbeEndpoint(context.Background(), Config{Endpoint: ts.URL + "/v1/traces", Batcher: kindOtlpHttp}))
+		require.NoError(t, ProbeEndpoint(context.Background(), Config{Endpoint: ts.URL + "/api/v2/spans", Batcher: kindZipkin}))
+		mu.Lock()
+		assert.Equal(t, []string{"HEAD /", "HEAD /api/v2/spans"}, methods)
+		mu.Unlock()
+
+		assert.Error(t, ProbeEndpoint(context.Background(), Config{Endpoint: dead, Batcher: kindOtlpHttp}))
+	})
+
+	t.Run("grpc", func(t *testing.T) {
+		require.NoError(t, ProbeEndpoint(context.Background(), Config{Endpoint: ts.URL, Batcher: kindOtlpGrpc}))
+		assert.Error(t, ProbeEndpoint(context.Background(), Config{Endpoint: dead, Batcher: kindOtlpGrpc}))
+	})
+
+	t.Run("all exporters", func(t *testing.T) {
+		err := ProbeEndpoint(context.Background(), Config{
+			Batcher: kindStdout,
+			Exporters: []ExporterConfig{
+				{Batcher: kindOtlpHttp, Endpoint: ts.URL},
+				{Batcher: kindOtlpGrpc, Endpoint: dead},
+			},
+		})
+		assert.ErrorContains(t, err, "probe otlpgrpc endpoint "+dead)
+		assert.NotContains(t, err.Error(), "otlphttp")
+	})
+
+	t.Run("on start", func(t *testing.T) {
+		_, err := StartAgent(zap.NewNop(), Config{Endpoint: dead, Batcher: kindOtlpHttp, ProbeOnStart: true})
+		assert.Error(t, err)
+
+		tp, err := StartAgent(zap.NewNop(), Config{Endpoint: ts.URL, Batcher: kindOtlpHttp, ProbeOnStart: true})
+		require.NoError(t, err)
+		assert.NoError(t, ShutdownAgent(context.Background(), tp))
+	})
+}
@@ -0,0 +1,73 @@
+package trace
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/trace"
+	"go.uber.org/zap"
+)
+
+func TestCreatePropagator(t *testing.T) {
+	t.Run("default", func(t *testing.T) {
+		p, err := createPropagator(nil)
+		require.NoError(t, err)
+		assert.ElementsMatch(t, []string{"traceparent", "tracestate", "baggage"}, p.Fields())
+	})
+
+	t.Run("b3 and jaeger", func(t *testing.T) {
+		p, err := createPropagator([]string{"b3", "jaeger"})
+		require.NoError(t, err)
+		assert.ElementsMatch(t, []string{"b3", "uber-trace-id"}, p.Fields())
+	})
+
+	t.Run("b3 round trip", func(t *testing.T) {
+		sc := trace.NewSpanContext(trace.SpanContextConfig{
+			TraceID:    traceID,
+			SpanID:     spanID,
+			TraceFlags: trace.FlagsSampled,
+		})
+		ctx := trace.ContextWithSpanContext(context.Background(), sc)
+
+		tests := map[string][]string{
+			propagatorB3:      {"b3"},
+			propagatorB3Multi: {"x-b3-traceid", "x-b3-spanid", "x-b3-sampled"},
+		}
+		for name, headers := range tests {
+			t.Run(name, func(t *testing.T) {
+				p, err := createPropagator([]string{name})
+				require.NoError(t, err)
+
+				carrier := propagation.MapCarrier{}
+				p.Inject(ctx, carrier)
+				assert.ElementsMatch(t, headers, carrier.Keys())
+
+				extracted := trace.SpanContextFromContext(p.Extract(context.Background(), carrier))
+				assert.Equal(t, sc.TraceID(), extracted.TraceID())
+				assert.Equal(t, sc.SpanID(), extracted.SpanID())
+				assert.True(t, extracted.IsSampled())
+				assert.True(t, extracted.IsRemote())
+			})
+		}
+	})
+
+	t.Run("unknown", func(t *testing.T) {
+		_, err := createPropagator([]string{"tracecontext", "xray"})
+		assert.EqualError(t, err, "unknown propagator: xray")
+	})
+}
+
+func TestStartAgentSetsPropagator(t *testing.T) {
+	defer otel.SetTextMapPropagator(otel.GetTextMapPropagator())
+
+	_, err := StartAgent(zap.NewNop(), Config{Name: "foo", Propagators: []string{"b3"}})
+	require.NoError(t, err)
+	assert.Equal(t, []string{"b3"}, otel.GetTextMapPropagator().Fields())
+
+	_, err = StartAgent(zap.NewNop(), Config{Name: "foo", Propagators: []string{"unknown"}})
+	assert.Error(t, err)
+}
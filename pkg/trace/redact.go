@@ -0,0 +1,64 @@
+package trace
+
+import (
+	"path"
+
+	"go.opentelemetry.io/otel/attribute"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+)
+
+const redactedValue = "[REDACTED]"
+
+// redactProcessor is a sdktrace.SpanProcessor which redacts the values of the
+// span and event attributes whose keys match one of the patterns before the
+// ended spans are passed to the wrapped processor.
+// The patterns use the syntax of path.Match, e.g. "http.request.header.*".
+type redactProcessor struct {
+	sdktrace.SpanProcessor
+	patterns []string
+}
+
+func (p *redactProcessor) OnEnd(s sdktrace.ReadOnlySpan) {
+	p.SpanProcessor.OnEnd(&redactedSpan{ReadOnlySpan: s, patterns: p.patterns})
+}
+
+// redactedSpan is a sdktrace.ReadOnlySpan with redacted attributes.
+type redactedSpan struct {
+	sdktrace.ReadOnlySpan
+	patterns []string
+}
+
+func (s *redactedSpan) Attributes() []attribute.KeyValue {
+	return redactAttributes(s.ReadOnlySpan.Attributes(), s.patterns)
+}
+
+func (s *redactedSpan) Events() []sdktrace.Event {
+	events := s.ReadOnlySpan.Events()
+	redacted := make([]sdktrace.Event, len(events))
+	for i, e := range events {
+		e.Attributes = redactAttributes(e.Attributes, s.patterns)
+		redacted[i] = e
+	}
+	return redacted
+}
+
+func redactAttributes(attrs []attribute.KeyValue, patterns []string) []attribute.KeyValue {
+	redacted := make([]attribute.KeyValue, len(attrs))
+	for i, kv := range attrs {
+		if matchesAny(string(kv.Key), patterns) {
+			kv.Value = attribute.StringValue(redactedValue)
+		}
+		redacted[i] = kv
+	}
+	return redacted
+}
+
+func matchesAny(key string, patterns []string) bool {
+	for _, pattern := range patterns {
+		// The patterns are validated by Config.Validate
+		if ok, _ := path.Match(pattern, key); ok {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,47 @@
+package trace
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/otel/attribute"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"go.opentelemetry.io/otel/trace"
+)
+
+func TestRedactProcessor(t *testing.T) {
+	exporter := tracetest.NewInMemoryExporter()
+	tp := sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(&redactProcessor{
+		SpanProcessor: sdktrace.NewSimpleSpanProcessor(exporter),
+		patterns:      []string{"password", "http.request.header.*"},
+	}))
+
+	_, span := tp.Tracer(TraceName).Start(context.Background(), "span", trace.WithAttributes(
+		attribute.String("user", "foo"),
+		attribute.String("password", "secret"),
+		attribute.StringSlice("http.request.header.authorization", []string{"Bearer token"}),
+	))
+	span.AddEvent("login", trace.WithAttributes(attribute.String("password", "secret")))
+	span.End()
+
+	spans := exporter.GetSpans()
+	require.Len(t, spans, 1)
+	assert.Equal(t, []attribute.KeyValue{
+		attribute.String("user", "foo"),
+		attribute.String("password", redactedValue),
+		attribute.String("http.request.header.authorization", redactedValue),
+	}, spans[0].Attributes)
+	require.Len(t, spans[0].Events, 1)
+	assert.Equal(t, []attribute.KeyValue{attribute.String("password", redactedValue)}, spans[0].Events[0].Attributes)
+
+	// The attributes of the span itself are not modified
+	assert.Contains(t, span.(sdktrace.ReadOnlySpan).Attributes(), attribute.String("password", "secret"))
+}
+
+func TestRedactAttributeKeysValidation(t *testing.T) {
+	err := Config{RedactAttributeKeys: []string{"["}}.Validate()
+	assert.ErrorContains(t, err, `invalid redact attribute key pattern "["`)
+}
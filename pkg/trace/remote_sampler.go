@@ -0,0 +1,174 @@
+package trace
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"go.opentelemetry.io/otel"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+)
+
+const (
+	defaultSamplingRefreshInterval = time.Minute
+	remoteSamplingTimeout          = 10 * time.Second
+)
+
+// remoteSampler samples with the strategy served by a Jaeger compatible
+// sampling endpoint, e.g. http://jaeger:5778/sampling. The strategy is
+// fetched in the background when spans are sampled and the refresh interval
+// has passed. Until the first successful fetch the fallback is used,
+// fetch errors keep the last good strategy and are passed to otel.Handle.
+// No fetches are started after stop.
+type remoteSampler struct {
+	url      string
+	interval time.Duration
+	client   *http.Client
+	now      func() time.Time
+
+	sampler  atomic.Pointer[sdktrace.Sampler]
+	fetching atomic.Bool
+
+	ctx    context.Context
+	cancel context.CancelFunc
+	wg     sync.WaitGroup
+
+	mu        sync.Mutex
+	lastFetch time.Time
+}
+
+func newRemoteSampler(c Config, fallback sdktrace.Sampler) (*remoteSampler, error) {
+	u, err := url.Parse(c.SamplingServerURL)
+	if err != nil {
+		return nil, fmt.Errorf("invalid sampling server url: %w", err)
+	}
+	q := u.Query()
+	q.Set("service", ServiceName(c))
+	u.RawQuery = q.Encode()
+
+	s := &remoteSampler{
+		url:      u.String(),
+		interval: c.SamplingRefreshInterval,
+		client:   &http.Client{Timeout: remoteSamplingTimeout},
+		now:      time.Now,
+	}
+	s.ctx, s.cancel = context.WithCancel(context.Background())
+	if s.interval <= 0 {
+		s.interval = defaultSamplingRefreshInterval
+	}
+	s.sampler.Store(&fallback)
+	return s, nil
+}
+
+func (s *remoteSampler) ShouldSample(p sdktrace.SamplingParameters) sdktrace.SamplingResult {
+	s.startRefresh()
+	return (*s.sampler.Load()).ShouldSample(p)
+}
+
+func (s *remoteSampler) Description() string {
+	return fmt.Sprintf("RemoteSampler{%s}", (*s.sampler.Load()).Description())
+}
+
+// startRefresh fetches the strategy in the background if the refresh interval
+// has passed since the last fetch and no fetch is running. The stop check and
+// the start of the fetch are done under s.mu, so stop never misses a fetch.
+func (s *remoteSampler) startRefresh() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.ctx.Err() != nil || !s.refreshDue() || !s.fetching.CompareAndSwap(false, true) {
+		return
+	}
+	s.wg.Add(1)
+	go func() {
+		defer s.wg.Done()
+		defer s.fetching.Store(false)
+		if err := s.refresh(s.ctx); err != nil && s.ctx.Err() == nil {
+			otel.Handle(err)
+		}
+	}()
+}
+
+// stop cancels a running fetch and waits for it to return.
+func (s *remoteSampler) stop() {
+	s.mu.Lock()
+	s.cancel()
+	s.mu.Unlock()
+	s.wg.Wait()
+}
+
+// refreshDue reports whether the refresh interval has passed since the last fetch.
+// It must be called with s.mu held.
+func (s *remoteSampler) refreshDue() bool {
+	return s.lastFetch.IsZero() || s.now().Sub(s.lastFetch) >= s.interval
+}
+
+// refresh fetches the sampling strategy and replaces the current sampler.
+func (s *remoteSampler) refresh(ctx context.Context) error {
+	s.mu.Lock()
+	s.lastFetch = s.now()
+	s.mu.Unlock()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, s.url, nil)
+	if err != nil {
+		return err
+	}
+	res, err := s.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("could not fetch sampling strategy: %w", err)
+	}
+	defer res.Body.Close()
+	if res.StatusCode != http.StatusOK {
+		return fmt.Errorf("could not fetch sampling strategy from %s: %s", s.url, res.Status)
+	}
+
+	var strategy samplingStrategy
+	if err := json.NewDecoder(res.Body).Decode(&strategy); err != nil {
+		return fmt.Errorf("invalid sampling strategy: %w", err)
+	}
+	sampler, err := strategy.sampler()
+	if err != nil {
+		return err
+	}
+	s.sampler.Store(&sampler)
+	return nil
+}
+
+// samplingStrategy is the sampling strategy response of the Jaeger sampling endpoints.
+type samplingStrategy struct {
+	// StrategyType is a name or number depending on the Jaeger version
+	StrategyType          json.RawMessage `json:"strategyType"`
+	ProbabilisticSampling *struct {
+		SamplingRate float64 `json:"samplingRate"`
+	} `json:"probabilisticSampling"`
+	RateLimitingSampling *struct {
+		MaxTracesPerSecond int `json:"maxTracesPerSecond"`
+	} `json:"rateLimitingSampling"`
+	OperationSampling *struct {
+		DefaultSamplingProbability float64 `json:"defaultSamplingProbability"`
+	} `json:"operationSampling"`
+}
+
+// sampler returns the root sampler of the strategy, spans with a parent follow it.
+// The per operation strategies aren't supported, their default probability is used.
+func (s samplingStrategy) sampler() (sdktrace.Sampler, error) {
+	var root sdktrace.Sampler
+	switch {
+	case s.OperationSampling != nil:
+		root = sdktrace.TraceIDRatioBased(s.OperationSampling.DefaultSamplingProbability)
+	case s.RateLimitingSampling != nil:
+		if s.RateLimitingSampling.MaxTracesPerSecond <= 0 {
+			return nil, fmt.Errorf("invalid sampling strategy: maxTracesPerSecond %d", s.RateLimitingSampling.MaxTracesPerSecond)
+		}
+		root = newRateLimitingSampler(s.RateLimitingSampling.MaxTracesPerSecond, sdktrace.AlwaysSample())
+	case s.ProbabilisticSampling != nil:
+		root = sdktrace.TraceIDRatioBased(s.ProbabilisticSampling.SamplingRate)
+	default:
+		return nil, fmt.Errorf("unsupported sampling strategy: %s", string(s.StrategyType))
+	}
+	return sdktrace.ParentBased(root), nil
+}
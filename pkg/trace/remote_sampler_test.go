@@ -0,0 +1,182 @@
+package trace
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/otel"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.uber.org/zap"
+)
+
+func TestRemoteSampler(t *testing.T) {
+	var (
+		mu       sync.Mutex
+		strategy = `{"strategyType":"PROBABILISTIC","probabilisticSampling":{"samplingRate":0.25}}`
+		status   = http.StatusOK
+		queries  []string
+	)
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		defer mu.Unlock()
+		queries = append(queries, r.URL.RawQuery)
+		w.WriteHeader(status)
+		_, _ = w.Write([]byte(strategy))
+	}))
+	defer ts.Close()
+
+	sampler, _, err := createSampler(Config{
+		Name:              "foo",
+		Sampler:           0.5,
+		SamplerType:       samplerRemote,
+		SamplingServerURL: ts.URL + "/sampling",
+	})
+	require.NoError(t, err)
+	remote := sampler.(*remoteSampler)
+	var nowMu sync.Mutex
+	now := time.Now()
+	remote.now = func() time.Time {
+		nowMu.Lock()
+		defer nowMu.Unlock()
+		return now
+	}
+	advance := func() {
+		nowMu.Lock()
+		defer nowMu.Unlock()
+		now = now.Add(defaultSamplingRefreshInterval)
+	}
+
+	// The fallback is used until the strategy is fetched
+	fallback := "RemoteSampler{" + sdktrace.ParentBased(sdktrace.TraceIDRatioBased(0.5)).Description() + "}"
+	fetched := "RemoteSampler{" + sdktrace.ParentBased(sdktrace.TraceIDRatioBased(0.25)).Description() + "}"
+	assert.Equal(t, fallback, sampler.Description())
+
+	sampler.ShouldSample(samplingParameters(context.Background(), "span"))
+	assert.Eventually(t, func() bool { return sampler.Description() == fetched }, time.Second, time.Millisecond)
+
+	mu.Lock()
+	assert.Equal(t, []string{"service=foo"}, queries)
+	strategy = `{"strategyType":"RATE_LIMITING","rateLimitingSampling":{"maxTracesPerSecond":3}}`
+	mu.Unlock()
+
+	// No fetch before the refresh interval has passed
+	require.Eventually(t, func() bool { return !remote.fetching.Load() }, time.Second, time.Millisecond)
+	sampler.ShouldSample(samplingParameters(context.Background(), "span"))
+	assert.False(t, remote.fetching.Load())
+
+	advance()
+	sampler.ShouldSample(samplingParameters(context.Background(), "span"))
+	limited := "RemoteSampler{" + sdktrace.ParentBased(newRateLimitingSampler(3, sdktrace.AlwaysSample())).Description() + "}"
+	assert.Eventually(t, func() bool { return sampler.Description() == limited }, time.Second, time.Millisecond)
+
+	t.Run("fetch error keeps the last strategy", func(t *testing.T) {
+		errs := make(chan error, 1)
+		otel.SetErrorHandler(otel.ErrorHandlerFunc(func(err error) { errs <- err }))
+		defer setErrorHandler(zap.NewNop(), nil)
+
+		mu.Lock()
+		status = http.StatusInternalServerError
+		mu.Unlock()
+
+		require.Eventually(t, func() bool { return !remote.fetching.Load() }, time.Second, time.Millisecond)
+		advance()
+		sampler.ShouldSample(samplingParameters(context.Background(), "span"))
+		select {
+		case err := <-errs:
+			assert.ErrorContains(t, err, "500 Internal Server Error")
+		case <-time.After(time.Second):
+			t.Fatal("fetch error not handled")
+		}
+		assert.Equal(t, limited, sampler.Description())
+	})
+}
+
+func TestRemoteSamplerFallback(t *testing.T) {
+	sampler, _, err := createSampler(Config{
+		SamplerType:       samplerRemote,
+		SamplingServerURL: "http://localhost:5778/sampling",
+		ParentBased:       ParentBasedSamplers{RemoteParentSampled: sdktrace.NeverSample()},
+	})
+	require.NoError(t, err)
+	fallback := sdktrace.ParentBased(sdktrace.TraceIDRatioBased(1), sdktrace.WithRemoteParentSampled(sdktrace.NeverSample()))
+	assert.Equal(t, "RemoteSampler{"+fallback.Description()+"}", sampler.Description())
+}
+
+func TestRemoteSamplerShutdown(t *testing.T) {
+	var requests atomic.Int32
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests.Add(1)
+		// Hang until the fetch is canceled
+		<-r.Context().Done()
+	}))
+	defer ts.Close()
+
+	tp, err := StartAgent(zap.NewNop(), Config{
+		SamplerType:       samplerRemote,
+		SamplingServerURL: ts.URL,
+		SetGlobal:         new(bool),
+	})
+	require.NoError(t, err)
+	_, span := tp.Tracer(TraceName).Start(context.Background(), "span")
+	span.End()
+	require.Eventually(t, func() bool { return requests.Load() == 1 }, time.Second, time.Millisecond)
+
+	// Waits for the hanging fetch to return
+	require.NoError(t, tp.Shutdown(context.Background()))
+
+	remote, _, err := createSampler(Config{SamplerType: samplerRemote, SamplingServerURL: ts.URL})
+	require.NoError(t, err)
+	remote.(*remoteSampler).stop()
+	remote.ShouldSample(samplingParameters(context.Background(), "span"))
+	assert.False(t, remote.(*remoteSampler).fetching.Load())
+	assert.Equal(t, int32(1), requests.Load())
+}
+
+func TestRemoteSamplerStopConcurrency(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer ts.Close()
+
+	sampler, _, err := createSampler(Config{SamplerType: samplerRemote, SamplingServerURL: ts.URL})
+	require.NoError(t, err)
+	remote := sampler.(*remoteSampler)
+	// Every call is due for a refresh
+	remote.interval = time.Nanosecond
+	otel.SetErrorHandler(otel.ErrorHandlerFunc(func(error) {}))
+	defer setErrorHandler(zap.NewNop(), nil)
+
+	var wg sync.WaitGroup
+	for i := 0; i < 4; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 100; j++ {
+				sampler.ShouldSample(samplingParameters(context.Background(), "span"))
+			}
+		}()
+	}
+	remote.stop()
+	wg.Wait()
+	assert.False(t, remote.fetching.Load())
+}
+
+func TestSamplingStrategy(t *testing.T) {
+	for _, s := range []samplingStrategy{
+		{StrategyType: []byte(`"PROBABILISTIC"`)},
+		{StrategyType: []byte(`0`)},
+		{RateLimitingSampling: &struct {
+			MaxTracesPerSecond int `json:"maxTracesPerSecond"`
+		}{}},
+	} {
+		_, err := s.sampler()
+		assert.Error(t, err)
+	}
+}
@@ -0,0 +1,140 @@
+package trace
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"sort"
+	"time"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/sdk/resource"
+	semconv "go.opentelemetry.io/otel/semconv/v1.4.0"
+	"go.uber.org/zap"
+)
+
+// resourceDetectionTimeout bounds the time spent on resource detection,
+// so a slow metadata endpoint can't block the agent startup.
+const resourceDetectionTimeout = 2 * time.Second
+
+const (
+	detectorHost      = "host"
+	detectorProcess   = "process"
+	detectorOS        = "os"
+	detectorContainer = "container"
+	detectorEnv       = "env"
+)
+
+// createResource creates the resource describing this application.
+// The custom resource attributes override the version and environment,
+// which override the attributes of OTEL_RESOURCE_ATTRIBUTES.
+// The service name always comes from ServiceName.
+func createResource(log *zap.Logger, c Config) *resource.Resource {
+	var attrs []attribute.KeyValue
+	if readEnvResource(c) {
+		attrs = resourceAttributesFromEnv(log)
+	}
+	if len(c.Version) > 0 {
+		attrs = append(attrs, semconv.ServiceVersionKey.String(c.Version))
+	}
+	if len(c.DeploymentEnvironment) > 0 {
+		attrs = append(attrs, semconv.DeploymentEnvironmentKey.String(c.DeploymentEnvironment))
+	}
+	// Later attributes win over earlier ones with the same key
+	for k, v := range c.ResourceAttributes {
+		attrs = append(attrs, attribute.String(k, v))
+	}
+	attrs = append(attrs, semconv.ServiceNameKey.String(ServiceName(c)))
+
+	res := resource.NewSchemaless(attrs...)
+	if len(c.SchemaURL) > 0 {
+		res = resource.NewWithAttributes(c.SchemaURL, attrs...)
+	}
+
+	if detectors := resourceDetectors(c); len(detectors) > 0 || len(c.ResourceOptions) > 0 {
+		detected, err := detectResource(detectors, c.ResourceOptions)
+		if err != nil {
+			log.Warn("detect resource error", zap.Error(err))
+			// Use the partially detected resource if there is one
+			if detected == nil {
+				return res
+			}
+		}
+		// Attributes of res take precedence over the detected ones
+		merged, err := resource.Merge(detected, res)
+		if err != nil {
+			log.Warn("merge resource error", zap.Error(err))
+			return res
+		}
+		return merged
+	}
+
+	return res
+}
+
+// resourceAttributesFromEnv returns the attributes of OTEL_RESOURCE_ATTRIBUTES
+// sorted by key. Malformed attributes are logged and ignored, the remaining
+// ones are still used.
+func resourceAttributesFromEnv(log *zap.Logger) []attribute.KeyValue {
+	v := os.Getenv(envResourceAttrs)
+	if v == "" {
+		return nil
+	}
+	// The skipped pairs are logged, so no error is returned
+	values, _ := parseKeyValues(v, "resource attribute", func(err error) {
+		log.Warn("invalid "+envResourceAttrs, zap.Error(err))
+	})
+
+	attrs := make([]attribute.KeyValue, 0, len(values))
+	for k, v := range values {
+		attrs = append(attrs, attribute.String(k, v))
+	}
+	sort.Slice(attrs, func(i, j int) bool {
+		return attrs[i].Key < attrs[j].Key
+	})
+	return attrs
+}
+
+// readEnvResource reports whether OTEL_RESOURCE_ATTRIBUTES is read, see Config.ReadEnvResource.
+func readEnvResource(c Config) bool {
+	return c.ReadEnvResource == nil || *c.ReadEnvResource
+}
+
+// resourceDetectors returns Config.Detectors and the default detectors if Config.DetectResources is set.
+// The default env detector reads OTEL_RESOURCE_ATTRIBUTES, too, it's left out if ReadEnvResource is false.
+func resourceDetectors(c Config) []string {
+	if !c.DetectResources {
+		return c.Detectors
+	}
+	defaults := []string{detectorHost, detectorProcess, detectorContainer}
+	if readEnvResource(c) {
+		defaults = append(defaults, detectorEnv)
+	}
+	return append(defaults, c.Detectors...)
+}
+
+// detectResource creates a resource from the detectors followed by the
+// options, so the attributes of later options override earlier ones.
+func detectResource(detectors []string, options []resource.Option) (*resource.Resource, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), resourceDetectionTimeout)
+	defer cancel()
+
+	opts := make([]resource.Option, 0, len(detectors)+len(options))
+	for _, name := range detectors {
+		switch name {
+		case detectorHost:
+			opts = append(opts, resource.WithHost())
+		case detectorProcess:
+			opts = append(opts, resource.WithProcess())
+		case detectorOS:
+			opts = append(opts, resource.WithOS())
+		case detectorContainer:
+			opts = append(opts, resource.WithContainer())
+		case detectorEnv:
+			opts = append(opts, resource.WithFromEnv())
+		default:
+			return nil, fmt.Errorf("unknown resource detector: %s", name)
+		}
+	}
+	return resource.New(ctx, append(opts, options...)...)
+}
@@ -0,0 +1,185 @@
+package trace
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	semconv17 "go.opentelemetry.io/otel/semconv/v1.17.0"
+	semconv "go.opentelemetry.io/otel/semconv/v1.4.0"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zaptest/observer"
+)
+
+func TestCreateResource(t *testing.T) {
+	t.Run("service name only", func(t *testing.T) {
+		res := createResource(zap.NewNop(), Config{Name: "foo"})
+		assert.Equal(t, []attribute.KeyValue{semconv.ServiceNameKey.String("foo")}, res.Attributes())
+	})
+
+	t.Run("service name can't be overridden", func(t *testing.T) {
+		res := createResource(zap.NewNop(), Config{
+			Name: "foo",
+			ResourceAttributes: map[string]string{
+				"service.name": "bar",
+			},
+		})
+		v, ok := res.Set().Value(semconv.ServiceNameKey)
+		require.True(t, ok)
+		assert.Equal(t, "foo", v.AsString())
+	})
+
+	t.Run("custom attributes override defaults", func(t *testing.T) {
+		res := createResource(zap.NewNop(), Config{
+			Name:                  "foo",
+			Version:               "1.0.0",
+			DeploymentEnvironment: "staging",
+			ResourceAttributes: map[string]string{
+				"deployment.environment": "production",
+			},
+		})
+		attrs := res.Attributes()
+		assert.Contains(t, attrs, semconv.ServiceVersionKey.String("1.0.0"))
+		assert.Contains(t, attrs, semconv.DeploymentEnvironmentKey.String("production"))
+	})
+
+	t.Run("environment", func(t *testing.T) {
+		t.Setenv(envResourceAttrs, "team=payments,service.name=env,service.version=0.1.0,region=us%2Deast%2D1")
+		res := createResource(zap.NewNop(), Config{
+			Name:    "foo",
+			Version: "1.0.0",
+		})
+		assert.Equal(t, []attribute.KeyValue{
+			attribute.String("region", "us-east-1"),
+			semconv.ServiceNameKey.String("foo"),
+			semconv.ServiceVersionKey.String("1.0.0"),
+			attribute.String("team", "payments"),
+		}, res.Attributes())
+	})
+
+	t.Run("environment ignored", func(t *testing.T) {
+		t.Setenv(envResourceAttrs, "team=payments")
+		readEnv := false
+		res := createResource(zap.NewNop(), Config{Name: "foo", ReadEnvResource: &readEnv})
+		assert.Equal(t, []attribute.KeyValue{semconv.ServiceNameKey.String("foo")}, res.Attributes())
+
+		res = createResource(zap.NewNop(), Config{Name: "foo", ReadEnvResource: &readEnv, DetectResources: true})
+		_, ok := res.Set().Value("team")
+		assert.False(t, ok)
+	})
+
+	t.Run("malformed environment", func(t *testing.T) {
+		t.Setenv(envResourceAttrs, "team,region=eu,=x,zone=%zz")
+		core, logs := observer.New(zap.WarnLevel)
+		res := createResource(zap.New(core), Config{Name: "foo"})
+		assert.Equal(t, []attribute.KeyValue{
+			attribute.String("region", "eu"),
+			semconv.ServiceNameKey.String("foo"),
+		}, res.Attributes())
+		assert.Equal(t, 3, logs.FilterMessage("invalid OTEL_RESOURCE_ATTRIBUTES").Len())
+	})
+
+	t.Run("schema url", func(t *testing.T) {
+		res := createResource(zap.NewNop(), Config{Name: "foo"})
+		assert.Empty(t, res.SchemaURL())
+
+		res = createResource(zap.NewNop(), Config{
+			Name:      "foo",
+			SchemaURL: semconv17.SchemaURL,
+			Detectors: []string{detectorHost},
+		})
+		assert.Equal(t, semconv17.SchemaURL, res.SchemaURL())
+		_, ok := res.Set().Value(semconv.HostNameKey)
+		assert.True(t, ok)
+	})
+
+	t.Run("detect resources", func(t *testing.T) {
+		res := createResource(zap.NewNop(), Config{
+			Name:            "foo",
+			DetectResources: true,
+		})
+		attrs := res.Attributes()
+		assert.Contains(t, attrs, semconv.ServiceNameKey.String("foo"))
+		_, ok := res.Set().Value(semconv.HostNameKey)
+		assert.True(t, ok)
+		_, ok = res.Set().Value(semconv.ProcessPIDKey)
+		assert.True(t, ok)
+	})
+
+	t.Run("detectors", func(t *testing.T) {
+		res := createResource(zap.NewNop(), Config{
+			Name:      "foo",
+			Detectors: []string{detectorHost, detectorOS},
+		})
+		assert.Contains(t, res.Attributes(), semconv.ServiceNameKey.String("foo"))
+		_, ok := res.Set().Value(semconv.HostNameKey)
+		assert.True(t, ok)
+		_, ok = res.Set().Value(semconv.OSTypeKey)
+		assert.True(t, ok)
+		_, ok = res.Set().Value(semconv.ProcessPIDKey)
+		assert.False(t, ok)
+	})
+
+	t.Run("resource options", func(t *testing.T) {
+		res := createResource(zap.NewNop(), Config{
+			Name:                  "foo",
+			DeploymentEnvironment: "production",
+			Detectors:             []string{detectorOS},
+			ResourceOptions: []resource.Option{
+				resource.WithAttributes(
+					attribute.String("region", "eu"),
+					semconv.DeploymentEnvironmentKey.String("staging"),
+					semconv.ServiceNameKey.String("bar"),
+				),
+			},
+		})
+		assert.Contains(t, res.Attributes(), attribute.String("region", "eu"))
+		// The package fields take precedence
+		assert.Contains(t, res.Attributes(), semconv.DeploymentEnvironmentKey.String("production"))
+		assert.Contains(t, res.Attributes(), semconv.ServiceNameKey.String("foo"))
+		_, ok := res.Set().Value(semconv.OSTypeKey)
+		assert.True(t, ok)
+	})
+
+	t.Run("unknown detector", func(t *testing.T) {
+		core, logs := observer.New(zap.WarnLevel)
+		res := createResource(zap.New(core), Config{
+			Name:      "foo",
+			Detectors: []string{"cloud"},
+		})
+		assert.Equal(t, []attribute.KeyValue{semconv.ServiceNameKey.String("foo")}, res.Attributes())
+		assert.Equal(t, 1, logs.FilterMessage("detect resource error").Len())
+
+		assert.ErrorContains(t, Config{Detectors: []string{"cloud"}}.Validate(), "unknown resource detector: cloud")
+	})
+}
+
+func TestStartAgentResourceAttributes(t *testing.T) {
+	tp, err := StartAgent(zap.NewNop(), Config{
+		Name:    "foo",
+		Version: "1.0.0",
+		Sampler: 1,
+		ResourceAttributes: map[string]string{
+			"deployment.environment": "production",
+			"team":                   "payments",
+		},
+	})
+	require.NoError(t, err)
+	defer tp.Shutdown(context.Background())
+
+	_, span := tp.Tracer(TraceName).Start(context.Background(), "span")
+	span.End()
+
+	ro, ok := span.(sdktrace.ReadOnlySpan)
+	require.True(t, ok)
+
+	attrs := ro.Resource().Attributes()
+	assert.Contains(t, attrs, semconv.ServiceNameKey.String("foo"))
+	assert.Contains(t, attrs, semconv.ServiceVersionKey.String("1.0.0"))
+	assert.Contains(t, attrs, semconv.DeploymentEnvironmentKey.String("production"))
+	assert.Contains(t, attrs, attribute.String("team", "payments"))
+}
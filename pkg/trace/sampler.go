@@ -0,0 +1,327 @@
+package trace
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/baggage"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/trace"
+)
+
+const (
+	samplerAlwaysOn         = "always_on"
+	samplerAlwaysOff        = "always_off"
+	samplerRatio            = "ratio"
+	samplerParentBasedRatio = "parentbased_ratio"
+	samplerRemote           = "remote"
+)
+
+// createSampler creates the sampler of Config.CustomSampler or Config.SamplerType.
+// Spans matching Config.SamplerRules or Config.DropSpanNamePrefixes are sampled
+// accordingly and the remaining ones are rate limited if Config.MaxTracesPerSecond is set.
+// Spans with the baggage key Config.ForceSampleOnBaggageKey are always sampled.
+// The returned samplerState allows to change the ratio of the ratio based samplers
+// and to stop the remote sampler.
+func createSampler(c Config) (sdktrace.Sampler, samplerState, error) {
+	var state samplerState
+	sampler := c.CustomSampler
+	if sampler == nil {
+		var err error
+		if sampler, state, err = createTypedSampler(c); err != nil {
+			return nil, samplerState{}, err
+		}
+	}
+	if len(c.SamplerRules) > 0 {
+		sampler = ruleSampler{rules: c.SamplerRules, delegate: sampler}
+	}
+	if len(c.DropSpanNamePrefixes) > 0 {
+		sampler = dropPrefixSampler{prefixes: c.DropSpanNamePrefixes, delegate: sampler}
+	}
+	if c.MaxTracesPerSecond > 0 {
+		sampler = newRateLimitingSampler(c.MaxTracesPerSecond, sampler)
+	}
+	if c.ForceSampleOnBaggageKey != "" {
+		sampler = forceSampler{key: c.ForceSampleOnBaggageKey, delegate: sampler}
+	}
+	return sampler, state, nil
+}
+
+// samplerState holds the samplers of createTypedSampler controlled after the start.
+type samplerState struct {
+	ratio  *ratioSampler
+	remote *remoteSampler
+}
+
+// samplerStateProcessor releases the sampler state of provider when it shuts
+// down, regardless of whether ShutdownAgent or the provider itself is called.
+type samplerStateProcessor struct {
+	state    samplerState
+	provider *sdktrace.TracerProvider
+}
+
+func (p *samplerStateProcessor) OnStart(context.Context, sdktrace.ReadWriteSpan) {}
+
+func (p *samplerStateProcessor) OnEnd(sdktrace.ReadOnlySpan) {}
+
+func (p *samplerStateProcessor) Shutdown(context.Context) error {
+	ratioSamplers.Delete(p.provider)
+	if p.state.remote != nil {
+		p.state.remote.stop()
+	}
+	return nil
+}
+
+func (p *samplerStateProcessor) ForceFlush(context.Context) error {
+	return nil
+}
+
+// createTypedSampler creates the sampler selected by Config.SamplerType.
+// Config.SamplerRatio or Config.Sampler is used as ratio for the ratio based samplers.
+func createTypedSampler(c Config) (sdktrace.Sampler, samplerState, error) {
+	switch c.SamplerType {
+	case samplerAlwaysOn:
+		return sdktrace.AlwaysSample(), samplerState{}, nil
+	case samplerAlwaysOff:
+		return sdktrace.NeverSample(), samplerState{}, nil
+	case samplerRatio:
+		ratio := newRatioSampler(configRatio(c))
+		return ratio, samplerState{ratio: ratio}, nil
+	case "", samplerParentBasedRatio:
+		ratio := newRatioSampler(configRatio(c))
+		return sdktrace.ParentBased(
+			ratio,
+			// By default of the parent span is sampled, the child span will be sampled.
+			c.ParentBased.options()...,
+		), samplerState{ratio: ratio}, nil
+	case samplerRemote:
+		// Used until the first strategy is fetched
+		fallback := sdktrace.ParentBased(sdktrace.TraceIDRatioBased(configRatio(c)), c.ParentBased.options()...)
+		remote, err := newRemoteSampler(c, fallback)
+		if err != nil {
+			return nil, samplerState{}, err
+		}
+		return remote, samplerState{remote: remote}, nil
+	default:
+		return nil, samplerState{}, fmt.Errorf("unknown sampler: %s", c.SamplerType)
+	}
+}
+
+// configRatio returns the ratio of the ratio based samplers of c.
+func configRatio(c Config) float64 {
+	if c.SamplerRatio != nil {
+		return math.Max(0, math.Min(*c.SamplerRatio, 1))
+	}
+	return clampRatio(c.Sampler)
+}
+
+// clampRatio returns the ratio for the ratio based samplers.
+// An unset ratio samples everything, out of range ratios are clamped to [0, 1].
+func clampRatio(ratio float64) float64 {
+	switch {
+	case ratio == 0, ratio > 1:
+		return 1
+	case ratio < 0:
+		return 0
+	default:
+		return ratio
+	}
+}
+
+// rateLimitingSampler is a leaky bucket sampler limiting the root spans
+// sampled by its delegate to maxTracesPerSecond. Spans with a parent are
+// left to the delegate.
+type rateLimitingSampler struct {
+	delegate           sdktrace.Sampler
+	maxTracesPerSecond int
+	now                func() time.Time
+
+	mu       sync.Mutex
+	balance  float64
+	lastTick time.Time
+}
+
+func newRateLimitingSampler(maxTracesPerSecond int, delegate sdktrace.Sampler) *rateLimitingSampler {
+	s := &rateLimitingSampler{
+		delegate:           delegate,
+		maxTracesPerSecond: maxTracesPerSecond,
+		now:                time.Now,
+	}
+	s.balance = float64(maxTracesPerSecond)
+	s.lastTick = s.now()
+	return s
+}
+
+func (s *rateLimitingSampler) ShouldSample(p sdktrace.SamplingParameters) sdktrace.SamplingResult {
+	res := s.delegate.ShouldSample(p)
+	if res.Decision != sdktrace.RecordAndSample || trace.SpanContextFromContext(p.ParentContext).IsValid() {
+		return res
+	}
+	if !s.take() {
+		return sdktrace.SamplingResult{
+			Decision:   sdktrace.Drop,
+			Tracestate: res.Tracestate,
+		}
+	}
+	return res
+}
+
+// take refills the bucket for the elapsed time and takes one trace from it.
+func (s *rateLimitingSampler) take() bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := s.now()
+	s.balance += now.Sub(s.lastTick).Seconds() * float64(s.maxTracesPerSecond)
+	if limit := float64(s.maxTracesPerSecond); s.balance > limit {
+		s.balance = limit
+	}
+	s.lastTick = now
+
+	if s.balance < 1 {
+		return false
+	}
+	s.balance--
+	return true
+}
+
+func (s *rateLimitingSampler) Description() string {
+	return fmt.Sprintf("RateLimitingSampler{%d,%s}", s.maxTracesPerSecond, s.delegate.Description())
+}
+
+// dropPrefixSampler drops all spans whose name starts with one of prefixes.
+// Other spans are left to the delegate.
+type dropPrefixSampler struct {
+	prefixes []string
+	delegate sdktrace.Sampler
+}
+
+func (s dropPrefixSampler) ShouldSample(p sdktrace.SamplingParameters) sdktrace.SamplingResult {
+	for _, prefix := range s.prefixes {
+		if strings.HasPrefix(p.Name, prefix) {
+			return sdktrace.SamplingResult{
+				Decision:   sdktrace.Drop,
+				Tracestate: trace.SpanContextFromContext(p.ParentContext).TraceState(),
+			}
+		}
+	}
+	return s.delegate.ShouldSample(p)
+}
+
+func (s dropPrefixSampler) Description() string {
+	return fmt.Sprintf("DropSpanNamePrefixes{%s,%s}", strings.Join(s.prefixes, ";"), s.delegate.Description())
+}
+
+// ruleSampler samples the spans whose start attributes match one of rules
+// according to the first matching rule. Other spans are left to the delegate.
+type ruleSampler struct {
+	rules    []SamplerRule
+	delegate sdktrace.Sampler
+}
+
+func (s ruleSampler) ShouldSample(p sdktrace.SamplingParameters) sdktrace.SamplingResult {
+	for _, rule := range s.rules {
+		if !hasAttribute(p.Attributes, rule.AttributeKey, rule.AttributeValue) {
+			continue
+		}
+		decision := sdktrace.Drop
+		if rule.Sample {
+			decision = sdktrace.RecordAndSample
+		}
+		return sdktrace.SamplingResult{
+			Decision:   decision,
+			Tracestate: trace.SpanContextFromContext(p.ParentContext).TraceState(),
+		}
+	}
+	return s.delegate.ShouldSample(p)
+}
+
+func (s ruleSampler) Description() string {
+	rules := make([]string, len(s.rules))
+	for i, rule := range s.rules {
+		decision := "drop"
+		if rule.Sample {
+			decision = "sample"
+		}
+		rules[i] = fmt.Sprintf("%s=%s:%s", rule.AttributeKey, rule.AttributeValue, decision)
+	}
+	return fmt.Sprintf("SamplerRules{%s,%s}", strings.Join(rules, ";"), s.delegate.Description())
+}
+
+// hasAttribute reports whether attrs contain key with the string representation value.
+func hasAttribute(attrs []attribute.KeyValue, key, value string) bool {
+	for _, kv := range attrs {
+		if string(kv.Key) == key && kv.Value.Emit() == value {
+			return true
+		}
+	}
+	return false
+}
+
+// forceSampler samples all spans whose context carries the baggage member key,
+// e.g. to debug specific requests. Other spans are left to the delegate.
+type forceSampler struct {
+	key      string
+	delegate sdktrace.Sampler
+}
+
+func (s forceSampler) ShouldSample(p sdktrace.SamplingParameters) sdktrace.SamplingResult {
+	if baggage.FromContext(p.ParentContext).Member(s.key).Key() == "" {
+		return s.delegate.ShouldSample(p)
+	}
+	return sdktrace.SamplingResult{
+		Decision:   sdktrace.RecordAndSample,
+		Tracestate: trace.SpanContextFromContext(p.ParentContext).TraceState(),
+	}
+}
+
+func (s forceSampler) Description() string {
+	return fmt.Sprintf("ForceSampleOnBaggageKey{%s,%s}", s.key, s.delegate.Description())
+}
+
+// ratioSamplers maps the providers started with a ratio based sampler to their ratioSampler
+var ratioSamplers sync.Map
+
+// SetSampleRatio changes the sampling ratio of a provider started with
+// a ratio based sampler, e.g. to sample more during an incident. The ratio
+// applies to new sampling decisions immediately. It's clamped to [0, 1],
+// unlike Config.Sampler a zero ratio samples nothing.
+func SetSampleRatio(tp *sdktrace.TracerProvider, ratio float64) error {
+	s, ok := ratioSamplers.Load(tp)
+	if !ok {
+		return errors.New("provider wasn't started with a ratio based sampler")
+	}
+	s.(*ratioSampler).setRatio(ratio)
+	return nil
+}
+
+// ratioSampler is a trace id ratio based sampler whose ratio can be changed concurrently.
+type ratioSampler struct {
+	sampler atomic.Pointer[sdktrace.Sampler]
+}
+
+func newRatioSampler(ratio float64) *ratioSampler {
+	s := &ratioSampler{}
+	s.setRatio(ratio)
+	return s
+}
+
+func (s *ratioSampler) setRatio(ratio float64) {
+	sampler := sdktrace.TraceIDRatioBased(math.Max(0, math.Min(ratio, 1)))
+	s.sampler.Store(&sampler)
+}
+
+func (s *ratioSampler) ShouldSample(p sdktrace.SamplingParameters) sdktrace.SamplingResult {
+	return (*s.sampler.Load()).ShouldSample(p)
+}
+
+func (s *ratioSampler) Description() string {
+	return (*s.sampler.Load()).Description()
+}
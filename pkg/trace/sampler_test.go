@@ -0,0 +1,395 @@
+package trace
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/baggage"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/trace"
+	"go.uber.org/zap"
+)
+
+func samplingParameters(ctx context.Context, name string) sdktrace.SamplingParameters {
+	return sdktrace.SamplingParameters{
+		ParentContext: ctx,
+		TraceID:       traceID,
+		Name:          name,
+	}
+}
+
+func sampledParentContext(sampled bool) context.Context {
+	var flags trace.TraceFlags
+	if sampled {
+		flags = trace.FlagsSampled
+	}
+	return trace.ContextWithRemoteSpanContext(context.Background(), trace.NewSpanContext(trace.SpanContextConfig{
+		TraceID:    traceID,
+		SpanID:     spanID,
+		TraceFlags: flags,
+		Remote:     true,
+	}))
+}
+
+func TestCreateSampler(t *testing.T) {
+	tests := []struct {
+		name     string
+		c        Config
+		root     sdktrace.SamplingDecision
+		unsample sdktrace.SamplingDecision
+	}{
+		{name: "always on", c: Config{SamplerType: samplerAlwaysOn}, root: sdktrace.RecordAndSample, unsample: sdktrace.RecordAndSample},
+		{name: "always off", c: Config{SamplerType: samplerAlwaysOff, Sampler: 1}, root: sdktrace.Drop, unsample: sdktrace.Drop},
+		{name: "ratio", c: Config{SamplerType: samplerRatio, Sampler: 1}, root: sdktrace.RecordAndSample, unsample: sdktrace.RecordAndSample},
+		{name: "parent based ratio", c: Config{SamplerType: samplerParentBasedRatio, Sampler: 1}, root: sdktrace.RecordAndSample, unsample: sdktrace.Drop},
+		{name: "default", c: Config{Sampler: 1}, root: sdktrace.RecordAndSample, unsample: sdktrace.Drop},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			sampler, _, err := createSampler(tt.c)
+			require.NoError(t, err)
+
+			res := sampler.ShouldSample(samplingParameters(context.Background(), "root"))
+			assert.Equal(t, tt.root, res.Decision)
+
+			res = sampler.ShouldSample(samplingParameters(sampledParentContext(false), "child"))
+			assert.Equal(t, tt.unsample, res.Decision)
+		})
+	}
+
+	_, _, err := createSampler(Config{SamplerType: "unknown"})
+	assert.EqualError(t, err, "unknown sampler: unknown")
+
+	_, err = StartAgent(zap.NewNop(), Config{Name: "foo", SamplerType: "unknown"})
+	assert.Error(t, err)
+}
+
+func TestParentBasedSamplers(t *testing.T) {
+	sampler, _, err := createSampler(Config{Sampler: 1})
+	require.NoError(t, err)
+	assert.Equal(t, sdktrace.ParentBased(sdktrace.AlwaysSample()).Description(), sampler.Description())
+
+	sampler, _, err = createSampler(Config{
+		Sampler: 1,
+		ParentBased: ParentBasedSamplers{
+			RemoteParentNotSampled: sdktrace.AlwaysSample(),
+			LocalParentSampled:     sdktrace.NeverSample(),
+		},
+	})
+	require.NoError(t, err)
+	assert.Equal(t, "ParentBased{root:AlwaysOnSampler,remoteParentSampled:AlwaysOnSampler,remoteParentNotSampled:AlwaysOnSampler,localParentSampled:AlwaysOffSampler,localParentNotSampled:AlwaysOffSampler}", sampler.Description())
+
+	res := sampler.ShouldSample(samplingParameters(sampledParentContext(false), "child"))
+	assert.Equal(t, sdktrace.RecordAndSample, res.Decision)
+	res = sampler.ShouldSample(samplingParameters(sampledParentContext(true), "child"))
+	assert.Equal(t, sdktrace.RecordAndSample, res.Decision)
+
+	local := trace.ContextWithSpanContext(context.Background(), trace.NewSpanContext(trace.SpanContextConfig{
+		TraceID:    traceID,
+		SpanID:     spanID,
+		TraceFlags: trace.FlagsSampled,
+	}))
+	res = sampler.ShouldSample(samplingParameters(local, "child"))
+	assert.Equal(t, sdktrace.Drop, res.Decision)
+}
+
+func TestSamplerRatio(t *testing.T) {
+	assert.Equal(t, 1.0, clampRatio(0))
+	assert.Equal(t, 0.5, clampRatio(0.5))
+	assert.Equal(t, 1.0, clampRatio(1))
+	assert.Equal(t, 1.0, clampRatio(1.5))
+	assert.Equal(t, 0.0, clampRatio(-1))
+
+	sampler, _, err := createSampler(Config{})
+	require.NoError(t, err)
+	res := sampler.ShouldSample(samplingParameters(context.Background(), "root"))
+	assert.Equal(t, sdktrace.RecordAndSample, res.Decision)
+
+	// An explicit zero samples nothing
+	zero, two := 0.0, 2.0
+	assert.Equal(t, 0.0, configRatio(Config{Sampler: 1, SamplerRatio: &zero}))
+	assert.Equal(t, 1.0, configRatio(Config{SamplerRatio: &two}))
+	sampler, _, err = createSampler(Config{SamplerRatio: &zero})
+	require.NoError(t, err)
+	res = sampler.ShouldSample(samplingParameters(context.Background(), "root"))
+	assert.Equal(t, sdktrace.Drop, res.Decision)
+}
+
+func TestRateLimitingSampler(t *testing.T) {
+	now := time.Unix(0, 0)
+	sampler := newRateLimitingSampler(2, sdktrace.ParentBased(sdktrace.AlwaysSample()))
+	sampler.now = func() time.Time { return now }
+	sampler.lastTick = now
+
+	assert.Equal(t, "RateLimitingSampler{2,ParentBased{root:AlwaysOnSampler,remoteParentSampled:AlwaysOnSampler,remoteParentNotSampled:AlwaysOffSampler,localParentSampled:AlwaysOnSampler,localParentNotSampled:AlwaysOffSampler}}", sampler.Description())
+
+	decisions := func(n int) (sampled int) {
+		for i := 0; i < n; i++ {
+			if sampler.ShouldSample(samplingParameters(context.Background(), "root")).Decision == sdktrace.RecordAndSample {
+				sampled++
+			}
+		}
+		return sampled
+	}
+
+	assert.Equal(t, 2, decisions(10))
+
+	// Children of sampled traces are not limited
+	res := sampler.ShouldSample(samplingParameters(sampledParentContext(true), "child"))
+	assert.Equal(t, sdktrace.RecordAndSample, res.Decision)
+
+	now = now.Add(500 * time.Millisecond)
+	assert.Equal(t, 1, decisions(10))
+
+	// The bucket does not fill beyond one second of traces
+	now = now.Add(time.Minute)
+	assert.Equal(t, 2, decisions(10))
+}
+
+func TestRateLimitingSamplerBurst(t *testing.T) {
+	const limit = 50
+	sampler := newRateLimitingSampler(limit, sdktrace.AlwaysSample())
+
+	start := time.Now()
+	var (
+		wg      sync.WaitGroup
+		sampled atomic.Int64
+	)
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 1000; j++ {
+				if sampler.ShouldSample(samplingParameters(context.Background(), "root")).Decision == sdktrace.RecordAndSample {
+					sampled.Add(1)
+				}
+			}
+		}()
+	}
+	wg.Wait()
+
+	// The full bucket plus the traces refilled while the burst ran
+	ceiling := limit + int64(time.Since(start).Seconds()*limit) + 1
+	assert.GreaterOrEqual(t, sampled.Load(), int64(limit))
+	assert.LessOrEqual(t, sampled.Load(), ceiling)
+
+	t.Run("dropped by delegate", func(t *testing.T) {
+		sampler := newRateLimitingSampler(1, sdktrace.TraceIDRatioBased(0))
+		for i := 0; i < 10; i++ {
+			assert.Equal(t, sdktrace.Drop, sampler.ShouldSample(samplingParameters(context.Background(), "root")).Decision)
+		}
+		// Spans dropped by the delegate don't take from the bucket
+		assert.Equal(t, float64(1), sampler.balance)
+	})
+}
+
+func TestCreateSamplerRateLimited(t *testing.T) {
+	sampler, _, err := createSampler(Config{SamplerType: samplerAlwaysOn, MaxTracesPerSecond: 5})
+	require.NoError(t, err)
+	assert.Equal(t, "RateLimitingSampler{5,AlwaysOnSampler}", sampler.Description())
+}
+
+func TestForceSampler(t *testing.T) {
+	sampler, _, err := createSampler(Config{SamplerType: samplerAlwaysOff, ForceSampleOnBaggageKey: "debug"})
+	require.NoError(t, err)
+	assert.Equal(t, "ForceSampleOnBaggageKey{debug,AlwaysOffSampler}", sampler.Description())
+
+	withBaggage := func(ctx context.Context, key string) context.Context {
+		m, err := baggage.NewMember(key, "true")
+		require.NoError(t, err)
+		b, err := baggage.New(m)
+		require.NoError(t, err)
+		return baggage.ContextWithBaggage(ctx, b)
+	}
+
+	res := sampler.ShouldSample(samplingParameters(context.Background(), "root"))
+	assert.Equal(t, sdktrace.Drop, res.Decision)
+
+	res = sampler.ShouldSample(samplingParameters(withBaggage(context.Background(), "other"), "root"))
+	assert.Equal(t, sdktrace.Drop, res.Decision)
+
+	res = sampler.ShouldSample(samplingParameters(withBaggage(context.Background(), "debug"), "root"))
+	assert.Equal(t, sdktrace.RecordAndSample, res.Decision)
+
+	res = sampler.ShouldSample(samplingParameters(withBaggage(sampledParentContext(false), "debug"), "child"))
+	assert.Equal(t, sdktrace.RecordAndSample, res.Decision)
+
+	t.Run("parent based delegation", func(t *testing.T) {
+		sampler, _, err := createSampler(Config{Sampler: 1, ForceSampleOnBaggageKey: "debug"})
+		require.NoError(t, err)
+
+		res := sampler.ShouldSample(samplingParameters(sampledParentContext(true), "child"))
+		assert.Equal(t, sdktrace.RecordAndSample, res.Decision)
+
+		res = sampler.ShouldSample(samplingParameters(sampledParentContext(false), "child"))
+		assert.Equal(t, sdktrace.Drop, res.Decision)
+	})
+}
+
+func TestDropPrefixSampler(t *testing.T) {
+	sampler, _, err := createSampler(Config{Sampler: 1, DropSpanNamePrefixes: []string{"GET /health", "metrics"}})
+	require.NoError(t, err)
+	assert.Equal(t, "DropSpanNamePrefixes{GET /health;metrics,ParentBased{root:AlwaysOnSampler,remoteParentSampled:AlwaysOnSampler,remoteParentNotSampled:AlwaysOffSampler,localParentSampled:AlwaysOnSampler,localParentNotSampled:AlwaysOffSampler}}", sampler.Description())
+
+	for name, want := range map[string]sdktrace.SamplingDecision{
+		"GET /health":       sdktrace.Drop,
+		"GET /healthz":      sdktrace.Drop,
+		"metrics scrape":    sdktrace.Drop,
+		"GET /graphql":      sdktrace.RecordAndSample,
+		"POST /GET /health": sdktrace.RecordAndSample,
+	} {
+		res := sampler.ShouldSample(samplingParameters(context.Background(), name))
+		assert.Equal(t, want, res.Decision, name)
+	}
+
+	t.Run("parent based", func(t *testing.T) {
+		res := sampler.ShouldSample(samplingParameters(sampledParentContext(true), "GET /health"))
+		assert.Equal(t, sdktrace.Drop, res.Decision)
+
+		res = sampler.ShouldSample(samplingParameters(sampledParentContext(true), "resolve"))
+		assert.Equal(t, sdktrace.RecordAndSample, res.Decision)
+
+		res = sampler.ShouldSample(samplingParameters(sampledParentContext(false), "resolve"))
+		assert.Equal(t, sdktrace.Drop, res.Decision)
+	})
+
+	t.Run("force sampled", func(t *testing.T) {
+		sampler, _, err := createSampler(Config{Sampler: 1, DropSpanNamePrefixes: []string{"GET /health"}, ForceSampleOnBaggageKey: "debug"})
+		require.NoError(t, err)
+
+		m, err := baggage.NewMember("debug", "true")
+		require.NoError(t, err)
+		b, err := baggage.New(m)
+		require.NoError(t, err)
+
+		res := sampler.ShouldSample(samplingParameters(baggage.ContextWithBaggage(context.Background(), b), "GET /health"))
+		assert.Equal(t, sdktrace.RecordAndSample, res.Decision)
+	})
+}
+
+func TestRuleSampler(t *testing.T) {
+	sampler, _, err := createSampler(Config{
+		SamplerType: samplerAlwaysOff,
+		SamplerRules: []SamplerRule{
+			{AttributeKey: "http.target", AttributeValue: "/health"},
+			{AttributeKey: "debug", AttributeValue: "true", Sample: true},
+			{AttributeKey: "http.target", AttributeValue: "/debug", Sample: true},
+		},
+	})
+	require.NoError(t, err)
+	assert.Equal(t, "SamplerRules{http.target=/health:drop;debug=true:sample;http.target=/debug:sample,AlwaysOffSampler}", sampler.Description())
+
+	shouldSample := func(ctx context.Context, attrs ...attribute.KeyValue) sdktrace.SamplingDecision {
+		p := samplingParameters(ctx, "span")
+		p.Attributes = attrs
+		return sampler.ShouldSample(p).Decision
+	}
+
+	assert.Equal(t, sdktrace.Drop, shouldSample(context.Background(), attribute.String("http.target", "/health")))
+	assert.Equal(t, sdktrace.RecordAndSample, shouldSample(context.Background(), attribute.Bool("debug", true)))
+	assert.Equal(t, sdktrace.RecordAndSample, shouldSample(context.Background(), attribute.String("http.target", "/debug")))
+	// The first matching rule wins
+	assert.Equal(t, sdktrace.Drop, shouldSample(context.Background(), attribute.Bool("debug", true), attribute.String("http.target", "/health")))
+	assert.Equal(t, sdktrace.Drop, shouldSample(context.Background(), attribute.String("http.target", "/graphql")))
+
+	t.Run("parent based delegation", func(t *testing.T) {
+		sampler, _, err := createSampler(Config{
+			Sampler:      1,
+			SamplerRules: []SamplerRule{{AttributeKey: "http.target", AttributeValue: "/health"}},
+		})
+		require.NoError(t, err)
+
+		p := samplingParameters(sampledParentContext(true), "span")
+		p.Attributes = []attribute.KeyValue{attribute.String("http.target", "/health")}
+		assert.Equal(t, sdktrace.Drop, sampler.ShouldSample(p).Decision)
+
+		p.Attributes = nil
+		assert.Equal(t, sdktrace.RecordAndSample, sampler.ShouldSample(p).Decision)
+
+		p = samplingParameters(sampledParentContext(false), "span")
+		assert.Equal(t, sdktrace.Drop, sampler.ShouldSample(p).Decision)
+	})
+}
+
+func TestCreateSamplerCustom(t *testing.T) {
+	sampler, _, err := createSampler(Config{
+		SamplerType:   samplerAlwaysOff,
+		Sampler:       0.5,
+		CustomSampler: sdktrace.AlwaysSample(),
+	})
+	require.NoError(t, err)
+	assert.Equal(t, sdktrace.AlwaysSample(), sampler)
+
+	sampler, _, err = createSampler(Config{
+		CustomSampler:      sdktrace.AlwaysSample(),
+		MaxTracesPerSecond: 1,
+	})
+	require.NoError(t, err)
+	assert.Equal(t, "RateLimitingSampler{1,AlwaysOnSampler}", sampler.Description())
+}
+
+func TestSetSampleRatio(t *testing.T) {
+	tp, err := StartAgent(zap.NewNop(), Config{Name: "foo", SamplerType: samplerRatio, Sampler: 1})
+	require.NoError(t, err)
+
+	sample := func() sdktrace.SamplingDecision {
+		s, ok := ratioSamplers.Load(tp)
+		require.True(t, ok)
+		return s.(*ratioSampler).ShouldSample(samplingParameters(context.Background(), "root")).Decision
+	}
+	assert.Equal(t, sdktrace.RecordAndSample, sample())
+
+	require.NoError(t, SetSampleRatio(tp, -1))
+	assert.Equal(t, sdktrace.Drop, sample())
+
+	_, span := tp.Tracer(TraceName).Start(context.Background(), "span")
+	assert.False(t, span.SpanContext().IsSampled())
+	span.End()
+
+	require.NoError(t, SetSampleRatio(tp, 2))
+	assert.Equal(t, sdktrace.RecordAndSample, sample())
+
+	_, span = tp.Tracer(TraceName).Start(context.Background(), "span")
+	assert.True(t, span.SpanContext().IsSampled())
+	span.End()
+
+	// Unlike Config.Sampler, zero samples nothing
+	require.NoError(t, SetSampleRatio(tp, 0))
+	assert.Equal(t, sdktrace.Drop, sample())
+
+	require.NoError(t, ShutdownAgent(context.Background(), tp))
+	assert.Error(t, SetSampleRatio(tp, 1))
+
+	// Shutting down the provider directly releases it too
+	tp, err = StartAgent(zap.NewNop(), Config{Name: "foo", SamplerType: samplerRatio})
+	require.NoError(t, err)
+	require.NoError(t, tp.Shutdown(context.Background()))
+	_, ok := ratioSamplers.Load(tp)
+	assert.False(t, ok)
+
+	tp, err = StartAgent(zap.NewNop(), Config{Name: "foo", SamplerType: samplerAlwaysOn})
+	require.NoError(t, err)
+	assert.EqualError(t, SetSampleRatio(tp, 1), "provider wasn't started with a ratio based sampler")
+}
+
+func TestRatioSamplerConcurrency(t *testing.T) {
+	s := newRatioSampler(1)
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for i := 0; i < 100; i++ {
+			s.setRatio(float64(i % 2))
+		}
+	}()
+	for i := 0; i < 100; i++ {
+		s.ShouldSample(samplingParameters(context.Background(), "root"))
+	}
+	<-done
+}
@@ -0,0 +1,44 @@
+package trace
+
+import (
+	"context"
+	"sort"
+
+	"go.opentelemetry.io/otel/attribute"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+)
+
+// defaultAttributesProcessor is a sdktrace.SpanProcessor which sets its
+// attributes on every started span, unless the span already has the key.
+type defaultAttributesProcessor struct {
+	attrs []attribute.KeyValue
+}
+
+func newDefaultAttributesProcessor(attrs map[string]string) *defaultAttributesProcessor {
+	p := &defaultAttributesProcessor{attrs: make([]attribute.KeyValue, 0, len(attrs))}
+	for k, v := range attrs {
+		p.attrs = append(p.attrs, attribute.String(k, v))
+	}
+	sort.Slice(p.attrs, func(i, j int) bool {
+		return p.attrs[i].Key < p.attrs[j].Key
+	})
+	return p
+}
+
+func (p *defaultAttributesProcessor) OnStart(_ context.Context, s sdktrace.ReadWriteSpan) {
+	existing := make(map[attribute.Key]struct{}, len(s.Attributes()))
+	for _, kv := range s.Attributes() {
+		existing[kv.Key] = struct{}{}
+	}
+	for _, kv := range p.attrs {
+		if _, ok := existing[kv.Key]; !ok {
+			s.SetAttributes(kv)
+		}
+	}
+}
+
+func (p *defaultAttributesProcessor) OnEnd(sdktrace.ReadOnlySpan) {}
+
+func (p *defaultAttributesProcessor) Shutdown(context.Context) error { return nil }
+
+func (p *defaultAttributesProcessor) ForceFlush(context.Context) error { return nil }
@@ -0,0 +1,121 @@
+package trace
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/otel/attribute"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"go.opentelemetry.io/otel/trace"
+	"go.uber.org/zap"
+)
+
+// startAttributesProcessor records the attributes of the spans when they are started.
+type startAttributesProcessor struct {
+	sdktrace.SpanProcessor
+	attrs [][]attribute.KeyValue
+}
+
+func (p *startAttributesProcessor) OnStart(ctx context.Context, s sdktrace.ReadWriteSpan) {
+	p.attrs = append(p.attrs, s.Attributes())
+	p.SpanProcessor.OnStart(ctx, s)
+}
+
+func TestDefaultAttributesProcessor(t *testing.T) {
+	exporter := tracetest.NewInMemoryExporter()
+	recorder := &startAttributesProcessor{SpanProcessor: sdktrace.NewSimpleSpanProcessor(exporter)}
+	tp := sdktrace.NewTracerProvider(
+		sdktrace.WithSpanProcessor(newDefaultAttributesProcessor(map[string]string{
+			"tenant.id": "acme",
+			"region":    "eu",
+		})),
+		sdktrace.WithSpanProcessor(recorder),
+	)
+
+	_, span := tp.Tracer(TraceName).Start(context.Background(), "span", trace.WithAttributes(
+		attribute.String("region", "us"),
+	))
+	span.End()
+
+	expected := []attribute.KeyValue{
+		attribute.String("region", "us"),
+		attribute.String("tenant.id", "acme"),
+	}
+	// Processors registered later see the attributes when the span is started
+	require.Len(t, recorder.attrs, 1)
+	assert.Equal(t, expected, recorder.attrs[0])
+
+	spans := exporter.GetSpans()
+	require.Len(t, spans, 1)
+	assert.Equal(t, expected, spans[0].Attributes)
+}
+
+func TestStartAgentDefaultSpanAttributes(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "spans.json")
+	tp, err := StartAgent(zap.NewNop(), Config{
+		Name:                  "foo",
+		Batcher:               kindFile,
+		FilePath:              path,
+		Sampler:               1,
+		SyncExport:            true,
+		DefaultSpanAttributes: map[string]string{"tenant.id": "acme"},
+	})
+	require.NoError(t, err)
+	defer ShutdownAgent(context.Background(), tp)
+
+	_, span := tp.Tracer(TraceName).Start(context.Background(), "span")
+	span.End()
+
+	data, err := os.ReadFile(path)
+	require.NoError(t, err)
+	assert.Contains(t, string(data), `{"Key":"tenant.id","Value":{"Type":"STRING","Value":"acme"}}`)
+}
+
+// orderProcessor records the ended spans and the spans exported at that time.
+type orderProcessor struct {
+	sdktrace.SpanProcessor
+	name     string
+	metrics  *ExporterMetrics
+	order    *[]string
+	exported int64
+}
+
+func (p *orderProcessor) OnEnd(s sdktrace.ReadOnlySpan) {
+	*p.order = append(*p.order, p.name)
+	p.exported = p.metrics.SpansExported()
+}
+
+func TestStartAgentSpanProcessors(t *testing.T) {
+	var (
+		m     ExporterMetrics
+		order []string
+	)
+	first := &orderProcessor{SpanProcessor: sdktrace.NewSimpleSpanProcessor(tracetest.NewNoopExporter()), name: "first", metrics: &m, order: &order}
+	second := &orderProcessor{SpanProcessor: first.SpanProcessor, name: "second", metrics: &m, order: &order}
+
+	tp, err := StartAgent(zap.NewNop(), Config{
+		Name:            "foo",
+		Batcher:         kindFile,
+		FilePath:        filepath.Join(t.TempDir(), "spans.json"),
+		Sampler:         1,
+		SyncExport:      true,
+		ExporterMetrics: &m,
+		SpanProcessors:  []sdktrace.SpanProcessor{first, second},
+	})
+	require.NoError(t, err)
+	defer ShutdownAgent(context.Background(), tp)
+
+	_, span := tp.Tracer(TraceName).Start(context.Background(), "span")
+	span.End()
+
+	assert.Equal(t, []string{"first", "second"}, order)
+	// The span is exported after the custom processors saw it
+	assert.Equal(t, int64(0), first.exported)
+	assert.Equal(t, int64(0), second.exported)
+	assert.Equal(t, int64(1), m.SpansExported())
+}
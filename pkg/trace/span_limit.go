@@ -0,0 +1,146 @@
+package trace
+
+import (
+	"context"
+	"sync"
+
+	"github.com/hashicorp/go-multierror"
+	"github.com/hashicorp/golang-lru/simplelru"
+	"go.opentelemetry.io/otel/codes"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/trace"
+	"go.uber.org/zap"
+)
+
+// spanLimitTraces is the number of traces whose spans are counted at once.
+const spanLimitTraces = 4096
+
+// spanLimitProcessor passes at most max ended spans per trace to the wrapped
+// processors, the others are dropped. The local root spans, spans without
+// parent or with a remote parent, are always passed on. A trace is removed
+// from the counted ones when its last open local root ends, e.g. the last of
+// several requests of the trace to this service. Traces whose roots don't end
+// in time are evicted when more than spanLimitTraces are counted.
+type spanLimitProcessor struct {
+	processors []sdktrace.SpanProcessor
+	max        int
+	log        *zap.Logger
+
+	mu     sync.Mutex
+	traces *simplelru.LRU // trace.TraceID to *traceSpans
+}
+
+// traceSpans counts the spans of a trace.
+type traceSpans struct {
+	// ended is the number of ended spans
+	ended int
+	// roots is the number of started local roots which haven't ended yet
+	roots int
+}
+
+func newSpanLimitProcessor(log *zap.Logger, max int, processors []sdktrace.SpanProcessor) *spanLimitProcessor {
+	// The size is positive, so there is no error
+	traces, _ := simplelru.NewLRU(spanLimitTraces, nil)
+	return &spanLimitProcessor{
+		processors: processors,
+		max:        max,
+		log:        log,
+		traces:     traces,
+	}
+}
+
+func (p *spanLimitProcessor) OnStart(parent context.Context, s sdktrace.ReadWriteSpan) {
+	if s.SpanContext().IsSampled() && isLocalRoot(s.Parent()) {
+		p.mu.Lock()
+		p.trace(s.SpanContext().TraceID()).roots++
+		p.mu.Unlock()
+	}
+	for _, sp := range p.processors {
+		sp.OnStart(parent, s)
+	}
+}
+
+func (p *spanLimitProcessor) OnEnd(s sdktrace.ReadOnlySpan) {
+	if !p.allow(s) {
+		return
+	}
+	for _, sp := range p.processors {
+		sp.OnEnd(s)
+	}
+}
+
+// allow counts s and reports whether it is within the limit of its trace.
+// Unsampled spans are recorded for the ErrorExporterEndpoint only, they are
+// not counted unless they would be rescued, so the mostly unsampled traffic
+// doesn't evict the counts of the sampled traces.
+func (p *spanLimitProcessor) allow(s sdktrace.ReadOnlySpan) bool {
+	traceID := s.SpanContext().TraceID()
+	root := isLocalRoot(s.Parent())
+	if !s.SpanContext().IsSampled() && s.Status().Code != codes.Error {
+		if root {
+			// Removes the counts of the rescued errors of the trace
+			p.mu.Lock()
+			if v, ok := p.traces.Peek(traceID); ok && v.(*traceSpans).roots <= 0 {
+				p.traces.Remove(traceID)
+			}
+			p.mu.Unlock()
+		}
+		return true
+	}
+
+	p.mu.Lock()
+	t := p.trace(traceID)
+	t.ended++
+	count := t.ended
+	if root {
+		t.roots--
+		// Also removes traces whose root was evicted before it ended
+		if t.roots <= 0 {
+			p.traces.Remove(traceID)
+		}
+	}
+	p.mu.Unlock()
+
+	if count == p.max+1 && !root {
+		p.log.Warn("span limit of trace exceeded, dropping spans",
+			zap.String("trace_id", traceID.String()),
+			zap.Int("max_spans_per_trace", p.max),
+		)
+	}
+	return root || count <= p.max
+}
+
+// trace returns the counts of the trace, p.mu must be held.
+func (p *spanLimitProcessor) trace(traceID trace.TraceID) *traceSpans {
+	if v, ok := p.traces.Get(traceID); ok {
+		return v.(*traceSpans)
+	}
+	t := &traceSpans{}
+	p.traces.Add(traceID, t)
+	return t
+}
+
+// isLocalRoot reports whether a span with the parent is the root of the trace in this process.
+func isLocalRoot(parent trace.SpanContext) bool {
+	return !parent.IsValid() || parent.IsRemote()
+}
+
+func (p *spanLimitProcessor) Shutdown(ctx context.Context) error {
+	var err error
+	for _, sp := range p.processors {
+		if spErr := sp.Shutdown(ctx); spErr != nil {
+			err = multierror.Append(err, spErr)
+		}
+	}
+	return err
+}
+
+func (p *spanLimitProcessor) ForceFlush(ctx context.Context) error {
+	var err error
+	for _, sp := range p.processors {
+		if spErr := sp.ForceFlush(ctx); spErr != nil {
+			err = multierror.Append(err, spErr)
+		}
+	}
+	return err
+}
@@ -0,0 +1,185 @@
+package trace
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/otel/codes"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"go.opentelemetry.io/otel/trace"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zaptest/observer"
+)
+
+func TestSpanLimitProcessor(t *testing.T) {
+	core, logs := observer.New(zap.WarnLevel)
+	sr := tracetest.NewSpanRecorder()
+	p := newSpanLimitProcessor(zap.New(core), 2, []sdktrace.SpanProcessor{sr})
+	tp := sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(p))
+	tracer := tp.Tracer(TraceName)
+
+	t.Run("cap", func(t *testing.T) {
+		ctx, root := tracer.Start(context.Background(), "root")
+		for i := 0; i < 5; i++ {
+			_, span := tracer.Start(ctx, "retry")
+			span.End()
+		}
+		assert.Equal(t, 2, len(sr.Ended()))
+		require.Len(t, sr.Started(), 6)
+
+		// The root is passed on although the limit is exceeded
+		root.End()
+		ended := sr.Ended()
+		require.Len(t, ended, 3)
+		assert.Equal(t, "root", ended[2].Name())
+
+		require.Equal(t, 1, logs.Len())
+		entry := logs.All()[0]
+		assert.Equal(t, root.SpanContext().TraceID().String(), entry.ContextMap()["trace_id"])
+		assert.Equal(t, int64(2), entry.ContextMap()["max_spans_per_trace"])
+	})
+
+	t.Run("remote parents", func(t *testing.T) {
+		logs.TakeAll()
+		remote := trace.ContextWithRemoteSpanContext(context.Background(), trace.NewSpanContext(trace.SpanContextConfig{
+			TraceID:    traceID,
+			SpanID:     spanID,
+			TraceFlags: trace.FlagsSampled,
+			Remote:     true,
+		}))
+		before := len(sr.Ended())
+
+		// Two requests of the same trace are served at once
+		first, firstRoot := tracer.Start(remote, "first")
+		second, secondRoot := tracer.Start(remote, "second")
+		_, span := tracer.Start(first, "child")
+		span.End()
+		firstRoot.End()
+		// The trace is still counted while the second request is served
+		assert.True(t, p.traces.Contains(traceID))
+		for i := 0; i < 3; i++ {
+			_, span := tracer.Start(second, "child")
+			span.End()
+		}
+		secondRoot.End()
+		assert.False(t, p.traces.Contains(traceID))
+
+		// The roots count, too, only the child of the first request is within the limit
+		assert.Len(t, sr.Ended()[before:], 3)
+		assert.Equal(t, 1, logs.Len())
+	})
+
+	t.Run("eviction", func(t *testing.T) {
+		ctx, root := tracer.Start(context.Background(), "root")
+		_, span := tracer.Start(ctx, "child")
+		span.End()
+		assert.True(t, p.traces.Contains(root.SpanContext().TraceID()))
+
+		// Ending the root completes the trace
+		root.End()
+		assert.False(t, p.traces.Contains(root.SpanContext().TraceID()))
+
+		// Traces whose root doesn't end are evicted
+		ctx, open := tracer.Start(context.Background(), "open")
+		_, span = tracer.Start(ctx, "child")
+		span.End()
+		for i := 0; i < spanLimitTraces; i++ {
+			ctx, _ := tracer.Start(context.Background(), "other")
+			_, span := tracer.Start(ctx, "child")
+			span.End()
+		}
+		assert.False(t, p.traces.Contains(open.SpanContext().TraceID()))
+		assert.Equal(t, spanLimitTraces, p.traces.Len())
+	})
+}
+
+func TestStartAgentMaxSpansPerTrace(t *testing.T) {
+	var m ExporterMetrics
+	tp, err := StartAgent(zap.NewNop(), Config{
+		Name:             "foo",
+		Sampler:          1,
+		Batcher:          kindFile,
+		FilePath:         filepath.Join(t.TempDir(), "spans.jsonl"),
+		SyncExport:       true,
+		ExporterMetrics:  &m,
+		MaxSpansPerTrace: 3,
+	})
+	require.NoError(t, err)
+	defer func() {
+		assert.NoError(t, ShutdownAgent(context.Background(), tp))
+	}()
+
+	ctx, root := tp.Tracer(TraceName).Start(context.Background(), "root")
+	for i := 0; i < 10; i++ {
+		_, span := tp.Tracer(TraceName).Start(ctx, "child")
+		span.End()
+	}
+	root.End()
+
+	assert.Equal(t, int64(4), m.SpansExported())
+}
+
+func TestStartAgentMaxSpansPerTraceErrorExporter(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer ts.Close()
+
+	var m ExporterMetrics
+	tp, err := StartAgent(zap.NewNop(), Config{
+		Name:                  "foo",
+		Batcher:               kindFile,
+		FilePath:              filepath.Join(t.TempDir(), "spans.jsonl"),
+		SamplerType:           samplerAlwaysOff,
+		SyncExport:            true,
+		ExporterMetrics:       &m,
+		ErrorExporterEndpoint: ts.URL,
+		MaxSpansPerTrace:      2,
+	})
+	require.NoError(t, err)
+	defer func() {
+		assert.NoError(t, ShutdownAgent(context.Background(), tp))
+	}()
+
+	tracer := tp.Tracer(TraceName)
+	ctx, root := tracer.Start(context.Background(), "root")
+	for i := 0; i < 5; i++ {
+		_, span := tracer.Start(ctx, "retry")
+		span.SetStatus(codes.Error, "failed")
+		span.End()
+	}
+	root.End()
+
+	// The rescued error spans are limited, too
+	assert.Equal(t, int64(2), m.SpansExported())
+}
+
+func TestSpanLimitProcessorUnsampled(t *testing.T) {
+	sr := tracetest.NewSpanRecorder()
+	p := newSpanLimitProcessor(zap.NewNop(), 2, []sdktrace.SpanProcessor{sr})
+	tp := sdktrace.NewTracerProvider(
+		sdktrace.WithSampler(recordUnsampledSampler{delegate: sdktrace.NeverSample()}),
+		sdktrace.WithSpanProcessor(p),
+	)
+	tracer := tp.Tracer(TraceName)
+
+	// Unsampled spans aren't counted, they don't evict the sampled traces
+	ctx, root := tracer.Start(context.Background(), "root")
+	_, span := tracer.Start(ctx, "child")
+	span.End()
+	assert.Equal(t, 0, p.traces.Len())
+
+	// Unless they are rescued errors
+	_, span = tracer.Start(ctx, "error")
+	span.SetStatus(codes.Error, "failed")
+	span.End()
+	assert.True(t, p.traces.Contains(root.SpanContext().TraceID()))
+
+	root.End()
+	assert.Equal(t, 0, p.traces.Len())
+	assert.Len(t, sr.Ended(), 3)
+}
@@ -0,0 +1,48 @@
+package trace
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"os"
+)
+
+// createTLSConfig creates the TLS config for the collector connection from
+// the configured certificate files and Config.TLSInsecureSkipVerify.
+// It returns nil if none of them is configured.
+func createTLSConfig(c Config) (*tls.Config, error) {
+	if len(c.TLSCertFile) == 0 && len(c.TLSKeyFile) == 0 && len(c.TLSCACertFile) == 0 && !c.TLSInsecureSkipVerify {
+		return nil, nil
+	}
+
+	tlsConfig := &tls.Config{
+		MinVersion: tls.VersionTLS12,
+		// Explicitly requested for test setups with self signed certificates
+		InsecureSkipVerify: c.TLSInsecureSkipVerify, //nolint:gosec
+	}
+
+	if len(c.TLSCACertFile) > 0 {
+		caCert, err := os.ReadFile(c.TLSCACertFile)
+		if err != nil {
+			return nil, fmt.Errorf("could not read CA certificate: %w", err)
+		}
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(caCert) {
+			return nil, fmt.Errorf("could not parse CA certificate %s: no valid PEM certificate found", c.TLSCACertFile)
+		}
+		tlsConfig.RootCAs = pool
+	}
+
+	if len(c.TLSCertFile) > 0 || len(c.TLSKeyFile) > 0 {
+		if len(c.TLSCertFile) == 0 || len(c.TLSKeyFile) == 0 {
+			return nil, fmt.Errorf("both TLS certificate and key file must be set for client authentication")
+		}
+		cert, err := tls.LoadX509KeyPair(c.TLSCertFile, c.TLSKeyFile)
+		if err != nil {
+			return nil, fmt.Errorf("could not load client certificate: %w", err)
+		}
+		tlsConfig.Certificates = []tls.Certificate{cert}
+	}
+
+	return tlsConfig, nil
+}
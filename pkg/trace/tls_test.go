@@ -0,0 +1,131 @@
+package trace
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+)
+
+// writeTestCertificate writes a self signed certificate and its key
+// to dir and returns the paths of both files.
+func writeTestCertificate(t *testing.T, dir string) (string, string) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+
+	template := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "localhost"},
+		NotBefore:             time.Now(),
+		NotAfter:              time.Now().Add(time.Hour),
+		IsCA:                  true,
+		BasicConstraintsValid: true,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	require.NoError(t, err)
+
+	keyDer, err := x509.MarshalECPrivateKey(key)
+	require.NoError(t, err)
+
+	certFile := filepath.Join(dir, "cert.pem")
+	keyFile := filepath.Join(dir, "key.pem")
+	require.NoError(t, os.WriteFile(certFile, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}), 0600))
+	require.NoError(t, os.WriteFile(keyFile, pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDer}), 0600))
+
+	return certFile, keyFile
+}
+
+func TestCreateTLSConfig(t *testing.T) {
+	dir := t.TempDir()
+	certFile, keyFile := writeTestCertificate(t, dir)
+
+	t.Run("no files", func(t *testing.T) {
+		tlsConfig, err := createTLSConfig(Config{})
+		require.NoError(t, err)
+		assert.Nil(t, tlsConfig)
+	})
+
+	t.Run("client certificate and CA", func(t *testing.T) {
+		tlsConfig, err := createTLSConfig(Config{
+			TLSCertFile:   certFile,
+			TLSKeyFile:    keyFile,
+			TLSCACertFile: certFile,
+		})
+		require.NoError(t, err)
+		assert.Len(t, tlsConfig.Certificates, 1)
+		assert.NotNil(t, tlsConfig.RootCAs)
+	})
+
+	t.Run("missing key", func(t *testing.T) {
+		_, err := createTLSConfig(Config{TLSCertFile: certFile})
+		assert.Error(t, err)
+	})
+
+	t.Run("missing file", func(t *testing.T) {
+		_, err := createTLSConfig(Config{TLSCACertFile: filepath.Join(dir, "missing.pem")})
+		assert.ErrorIs(t, err, os.ErrNotExist)
+	})
+
+	t.Run("bad PEM", func(t *testing.T) {
+		_, err := createTLSConfig(Config{TLSCACertFile: keyFile})
+		assert.ErrorContains(t, err, "no valid PEM certificate found")
+	})
+
+	t.Run("insecure skip verify", func(t *testing.T) {
+		tlsConfig, err := createTLSConfig(Config{TLSInsecureSkipVerify: true})
+		require.NoError(t, err)
+		assert.True(t, tlsConfig.InsecureSkipVerify)
+
+		var received atomic.Bool
+		ts := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			received.Store(true)
+		}))
+		defer ts.Close()
+
+		tp, err := StartAgent(zap.NewNop(), Config{
+			Name:                  "foo",
+			Endpoint:              ts.URL,
+			Batcher:               kindOtlpHttp,
+			Sampler:               1,
+			TLSInsecureSkipVerify: true,
+		})
+		require.NoError(t, err)
+		_, span := tp.Tracer(TraceName).Start(context.Background(), "span")
+		span.End()
+		require.NoError(t, ShutdownAgent(context.Background(), tp))
+		assert.True(t, received.Load())
+	})
+
+	t.Run("exporter", func(t *testing.T) {
+		for _, batcher := range []string{kindOtlpHttp, kindOtlpGrpc} {
+			_, err := createExporter(context.Background(), Config{
+				Endpoint:      "https://localhost:4318",
+				Batcher:       batcher,
+				TLSCACertFile: certFile,
+			})
+			assert.NoError(t, err)
+
+			_, err = createExporter(context.Background(), Config{
+				Endpoint:      "https://localhost:4318",
+				Batcher:       batcher,
+				TLSCACertFile: keyFile,
+			})
+			assert.Error(t, err)
+		}
+	})
+}
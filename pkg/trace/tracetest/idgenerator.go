@@ -0,0 +1,43 @@
+package tracetest
+
+import (
+	"context"
+	"encoding/binary"
+	"sync"
+
+	"go.opentelemetry.io/otel/trace"
+)
+
+// SequentialIDGenerator generates predictable trace and span IDs for tests.
+// The n-th trace ID and span ID both end with n in big endian, starting at 1,
+// e.g. 00000000000000000000000000000001 and 0000000000000001.
+// The zero value is ready to use and it is safe for concurrent use.
+type SequentialIDGenerator struct {
+	mu      sync.Mutex
+	traceID uint64
+	spanID  uint64
+}
+
+// NewIDs returns the next trace ID and span ID.
+func (g *SequentialIDGenerator) NewIDs(ctx context.Context) (trace.TraceID, trace.SpanID) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	g.traceID++
+	var tid trace.TraceID
+	binary.BigEndian.PutUint64(tid[8:], g.traceID)
+	return tid, g.nextSpanID()
+}
+
+// NewSpanID returns the next span ID.
+func (g *SequentialIDGenerator) NewSpanID(ctx context.Context, traceID trace.TraceID) trace.SpanID {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	return g.nextSpanID()
+}
+
+func (g *SequentialIDGenerator) nextSpanID() trace.SpanID {
+	g.spanID++
+	var sid trace.SpanID
+	binary.BigEndian.PutUint64(sid[:], g.spanID)
+	return sid
+}
@@ -0,0 +1,24 @@
+package tracetest
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"go.opentelemetry.io/otel/sdk/trace"
+)
+
+func TestSequentialIDGenerator(t *testing.T) {
+	tp := trace.NewTracerProvider(trace.WithIDGenerator(&SequentialIDGenerator{}))
+
+	ctx, parent := tp.Tracer("test").Start(context.Background(), "parent")
+	_, child := tp.Tracer("test").Start(ctx, "child")
+	_, other := tp.Tracer("test").Start(context.Background(), "other")
+
+	assert.Equal(t, "00000000000000000000000000000001", parent.SpanContext().TraceID().String())
+	assert.Equal(t, "0000000000000001", parent.SpanContext().SpanID().String())
+	assert.Equal(t, parent.SpanContext().TraceID(), child.SpanContext().TraceID())
+	assert.Equal(t, "0000000000000002", child.SpanContext().SpanID().String())
+	assert.Equal(t, "00000000000000000000000000000002", other.SpanContext().TraceID().String())
+	assert.Equal(t, "0000000000000003", other.SpanContext().SpanID().String())
+}
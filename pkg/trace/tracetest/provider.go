@@ -0,0 +1,35 @@
+package tracetest
+
+import (
+	"sync"
+
+	"go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+)
+
+// recorders maps the providers created by NewTestProvider to their recorder
+var recorders sync.Map
+
+// NewTestProvider returns a new TracerProvider recording all spans in memory.
+// Unlike NewInMemoryExporter it doesn't set the global provider.
+// Use RecordedSpans to retrieve the recorded spans.
+func NewTestProvider() *trace.TracerProvider {
+	sr := tracetest.NewSpanRecorder()
+	tp := trace.NewTracerProvider(
+		trace.WithSampler(trace.AlwaysSample()),
+		trace.WithSpanProcessor(sr),
+	)
+	recorders.Store(tp, sr)
+
+	return tp
+}
+
+// RecordedSpans returns the spans ended on a provider created by NewTestProvider
+// in the order they were ended. It returns nil for any other provider.
+func RecordedSpans(tp *trace.TracerProvider) []trace.ReadOnlySpan {
+	sr, ok := recorders.Load(tp)
+	if !ok {
+		return nil
+	}
+	return sr.(*tracetest.SpanRecorder).Ended()
+}
@@ -0,0 +1,30 @@
+package tracetest
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/sdk/trace"
+)
+
+func TestNewTestProvider(t *testing.T) {
+	global := otel.GetTracerProvider()
+
+	tp := NewTestProvider()
+	assert.Equal(t, global, otel.GetTracerProvider())
+
+	_, span := tp.Tracer("test").Start(context.Background(), "foo")
+	span.SetAttributes(attribute.String("key", "value"))
+	span.End()
+
+	spans := RecordedSpans(tp)
+	require.Len(t, spans, 1)
+	assert.Equal(t, "foo", spans[0].Name())
+	assert.Equal(t, []attribute.KeyValue{attribute.String("key", "value")}, spans[0].Attributes())
+
+	assert.Nil(t, RecordedSpans(trace.NewTracerProvider()))
+}
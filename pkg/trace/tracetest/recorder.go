@@ -0,0 +1,58 @@
+package tracetest
+
+import (
+	"context"
+	"sync"
+
+	"go.opentelemetry.io/otel/sdk/trace"
+)
+
+// assert that SpanRecorder implements the trace.SpanExporter interface
+var _ trace.SpanExporter = (*SpanRecorder)(nil)
+
+// SpanRecorder is a trace.SpanExporter keeping the exported spans in memory.
+type SpanRecorder struct {
+	mu    sync.Mutex
+	spans []trace.ReadOnlySpan
+}
+
+// NewTestAgent returns a new TracerProvider sampling all spans and exporting
+// them synchronously to the returned SpanRecorder. It doesn't set the global provider.
+func NewTestAgent() (*trace.TracerProvider, *SpanRecorder) {
+	sr := &SpanRecorder{}
+	tp := trace.NewTracerProvider(
+		trace.WithSampler(trace.AlwaysSample()),
+		trace.WithSyncer(sr),
+	)
+	return tp, sr
+}
+
+func (r *SpanRecorder) ExportSpans(ctx context.Context, spans []trace.ReadOnlySpan) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.spans = append(r.spans, spans...)
+	return nil
+}
+
+func (r *SpanRecorder) Shutdown(ctx context.Context) error {
+	return nil
+}
+
+// Ended returns the ended spans in the order they were ended.
+func (r *SpanRecorder) Ended() []trace.ReadOnlySpan {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	spans := make([]trace.ReadOnlySpan, len(r.spans))
+	copy(spans, r.spans)
+	return spans
+}
+
+// Reset removes all recorded spans.
+func (r *SpanRecorder) Reset() {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.spans = nil
+}
@@ -0,0 +1,26 @@
+package tracetest
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/otel/codes"
+)
+
+func TestNewTestAgent(t *testing.T) {
+	tp, sr := NewTestAgent()
+
+	_, span := tp.Tracer("test").Start(context.Background(), "foo")
+	span.SetStatus(codes.Error, "failed")
+	span.End()
+
+	spans := sr.Ended()
+	require.Len(t, spans, 1)
+	assert.Equal(t, "foo", spans[0].Name())
+	assert.Equal(t, codes.Error, spans[0].Status().Code)
+
+	sr.Reset()
+	assert.Empty(t, sr.Ended())
+}
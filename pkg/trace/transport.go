@@ -22,6 +22,17 @@ func NewTransport(base http.RoundTripper, opts ...otelhttp.Option) http.RoundTri
 	)
 }
 
+// Transport wraps base with NewTransport to trace outgoing requests. The
+// trace context is injected into the request headers with the global
+// propagator and a client span records the host, method and status code.
+// If base is nil, http.DefaultTransport is used.
+func Transport(base http.RoundTripper) http.RoundTripper {
+	if base == nil {
+		base = http.DefaultTransport
+	}
+	return NewTransport(base)
+}
+
 type transport struct {
 	rt http.RoundTripper
 }
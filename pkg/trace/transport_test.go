@@ -9,7 +9,9 @@ import (
 	"testing"
 
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 	"go.opentelemetry.io/contrib/instrumentation/net/http/otelhttp"
+	"go.opentelemetry.io/otel"
 	"go.opentelemetry.io/otel/codes"
 	sdktrace "go.opentelemetry.io/otel/sdk/trace"
 	semconv "go.opentelemetry.io/otel/semconv/v1.17.0"
@@ -119,3 +121,35 @@ func TestTransport(t *testing.T) {
 		assert.Contains(t, sn[0].Attributes(), WgComponentName.String("test"))
 	})
 }
+
+func TestTransportDefault(t *testing.T) {
+	exporter := tracetest.NewInMemoryExporter(t)
+
+	var traceparent string
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		traceparent = r.Header.Get("traceparent")
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer ts.Close()
+
+	ctx, span := otel.Tracer(TraceName).Start(context.Background(), "parent")
+	r, err := http.NewRequestWithContext(ctx, http.MethodPost, ts.URL+"/test", nil)
+	require.NoError(t, err)
+
+	c := http.Client{Transport: Transport(nil)}
+	res, err := c.Do(r)
+	require.NoError(t, err)
+	require.NoError(t, res.Body.Close())
+	span.End()
+
+	sn := exporter.GetSpans().Snapshots()
+	require.Len(t, sn, 2)
+	assert.Equal(t, "POST /test", sn[0].Name())
+	assert.Equal(t, trace.SpanKindClient, sn[0].SpanKind())
+	assert.Equal(t, span.SpanContext().SpanID(), sn[0].Parent().SpanID())
+	assert.Contains(t, sn[0].Attributes(), semconv.HTTPMethod(http.MethodPost))
+	assert.Contains(t, sn[0].Attributes(), semconv.NetPeerName("127.0.0.1"))
+	assert.Contains(t, sn[0].Attributes(), semconv.HTTPStatusCode(http.StatusServiceUnavailable))
+	assert.Equal(t, codes.Error, sn[0].Status().Code)
+	assert.Contains(t, traceparent, sn[0].SpanContext().SpanID().String())
+}
@@ -4,8 +4,13 @@ import (
 	"context"
 	"fmt"
 	"net/http"
+	"runtime"
+	"strings"
 
 	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	semconv17 "go.opentelemetry.io/otel/semconv/v1.17.0"
 	"go.opentelemetry.io/otel/trace"
 
 	"github.com/wundergraph/wundergraph/pkg/operation"
@@ -22,6 +27,87 @@ func TracerFromContext(ctx context.Context) (tracer trace.Tracer) {
 	return
 }
 
+// Tracer returns a tracer of the global provider. The provider of StartAgent
+// is only used if it's installed globally, see Config.SetGlobal.
+// An empty name defaults to the service name of the agent.
+func Tracer(name string) trace.Tracer {
+	if name == "" {
+		tpMu.Lock()
+		name = serviceName
+		tpMu.Unlock()
+	}
+	if name == "" {
+		name = TraceName
+	}
+	return otel.Tracer(name)
+}
+
+// StartSpan starts a span with the tracer of Tracer("").
+func StartSpan(ctx context.Context, name string, opts ...trace.SpanStartOption) (context.Context, trace.Span) {
+	return Tracer("").Start(ctx, name, opts...)
+}
+
+// Start starts a span with the tracer of Tracer(""). An empty name defaults to
+// the function calling Start, e.g. "node.(*Node).StartBlocking".
+func Start(ctx context.Context, name string, opts ...trace.SpanStartOption) (context.Context, trace.Span) {
+	if name == "" {
+		// Only looked up when needed, it's more expensive than the span start
+		name = callerName(2)
+	}
+	return Tracer("").Start(ctx, name, opts...)
+}
+
+// callerName returns the package qualified name of the function skip frames
+// up the stack, skip 0 is callerName itself.
+func callerName(skip int) string {
+	pc, _, _, ok := runtime.Caller(skip)
+	if !ok {
+		return "unknown"
+	}
+	fn := runtime.FuncForPC(pc)
+	if fn == nil {
+		return "unknown"
+	}
+	name := fn.Name()
+	// Strip the import path but keep the package name
+	if i := strings.LastIndex(name, "/"); i >= 0 {
+		name = name[i+1:]
+	}
+	return name
+}
+
+// StartOperationSpan starts a span for a GraphQL operation with the tracer of
+// Tracer(""). The span is named after the operation type and name, and records
+// the operation attributes of the GraphQL semantic conventions. The document
+// is recorded as is, so it should not contain sensitive values.
+func StartOperationSpan(ctx context.Context, opName, opType, document string) (context.Context, trace.Span) {
+	name := opType
+	attrs := []attribute.KeyValue{
+		semconv17.GraphqlOperationTypeKey.String(opType),
+		semconv17.GraphqlDocument(document),
+	}
+	if opName != "" {
+		name += " " + opName
+		attrs = append(attrs, semconv17.GraphqlOperationName(opName))
+	}
+	return StartSpan(ctx, name, trace.WithAttributes(attrs...))
+}
+
+// RecordError records err as exception event on span and sets the span
+// status to error. It's a no-op for a nil err.
+func RecordError(span trace.Span, err error, opts ...trace.EventOption) {
+	if err == nil {
+		return
+	}
+	span.RecordError(err, opts...)
+	span.SetStatus(codes.Error, err.Error())
+}
+
+// SetOK sets the status of span to ok, which overrides any error status.
+func SetOK(span trace.Span) {
+	span.SetStatus(codes.Ok, "")
+}
+
 func SetOperationAttributes(ctx context.Context) {
 	span := trace.SpanFromContext(ctx)
 	if span.SpanContext().IsValid() {
@@ -2,14 +2,22 @@ package trace
 
 import (
 	"context"
+	"errors"
 	"testing"
 
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
 	"go.opentelemetry.io/otel/sdk/resource"
 	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	semconv17 "go.opentelemetry.io/otel/semconv/v1.17.0"
 	semconv "go.opentelemetry.io/otel/semconv/v1.4.0"
 	"go.opentelemetry.io/otel/trace"
+	"go.uber.org/zap"
+
+	"github.com/wundergraph/wundergraph/pkg/trace/tracetest"
 )
 
 func TestTracerFromContext(t *testing.T) {
@@ -58,3 +66,111 @@ func TestTracerFromContext(t *testing.T) {
 		traceFn(context.Background(), false)
 	})
 }
+
+func TestTracer(t *testing.T) {
+	exporter := tracetest.NewInMemoryExporter(t)
+
+	tpMu.Lock()
+	serviceName = ""
+	tpMu.Unlock()
+
+	_, span := StartSpan(context.Background(), "default")
+	span.End()
+	_, span = Tracer("custom").Start(context.Background(), "custom")
+	span.End()
+
+	setGlobal := false
+	_, err := StartAgent(zap.NewNop(), Config{Name: "ignored", SetGlobal: &setGlobal})
+	require.NoError(t, err)
+	_, span = StartSpan(context.Background(), "not global")
+	span.End()
+
+	spans := exporter.GetSpans().Snapshots()
+	require.Len(t, spans, 3)
+	assert.Equal(t, TraceName, spans[0].InstrumentationScope().Name)
+	assert.Equal(t, "custom", spans[1].InstrumentationScope().Name)
+	assert.Equal(t, TraceName, spans[2].InstrumentationScope().Name)
+
+	tp, err := StartAgent(zap.NewNop(), Config{Name: "service", Sampler: 1})
+	require.NoError(t, err)
+	defer func() {
+		assert.NoError(t, ShutdownAgent(context.Background(), tp))
+	}()
+
+	_, span = StartSpan(context.Background(), "service")
+	defer span.End()
+	assert.True(t, span.SpanContext().IsSampled())
+	assert.Equal(t, "service", span.(sdktrace.ReadOnlySpan).InstrumentationScope().Name)
+}
+
+func TestStart(t *testing.T) {
+	exporter := tracetest.NewInMemoryExporter(t)
+
+	_, span := Start(context.Background(), "doWork", trace.WithSpanKind(trace.SpanKindInternal))
+	span.End()
+	_, span = Start(context.Background(), "")
+	span.End()
+	func() {
+		_, span := Start(context.Background(), "")
+		span.End()
+	}()
+
+	spans := exporter.GetSpans().Snapshots()
+	require.Len(t, spans, 3)
+	assert.Equal(t, "doWork", spans[0].Name())
+	assert.Equal(t, trace.SpanKindInternal, spans[0].SpanKind())
+	assert.Equal(t, "trace.TestStart", spans[1].Name())
+	assert.Equal(t, "trace.TestStart.func1", spans[2].Name())
+}
+
+func TestStartOperationSpan(t *testing.T) {
+	exporter := tracetest.NewInMemoryExporter(t)
+
+	_, span := StartOperationSpan(context.Background(), "Weather", "query", "query Weather { weather { temperature } }")
+	span.End()
+	_, span = StartOperationSpan(context.Background(), "", "mutation", "mutation { login }")
+	span.End()
+
+	spans := exporter.GetSpans().Snapshots()
+	require.Len(t, spans, 2)
+
+	assert.Equal(t, "query Weather", spans[0].Name())
+	assert.ElementsMatch(t, []attribute.KeyValue{
+		semconv17.GraphqlOperationTypeQuery,
+		semconv17.GraphqlOperationName("Weather"),
+		semconv17.GraphqlDocument("query Weather { weather { temperature } }"),
+	}, spans[0].Attributes())
+
+	assert.Equal(t, "mutation", spans[1].Name())
+	assert.ElementsMatch(t, []attribute.KeyValue{
+		semconv17.GraphqlOperationTypeMutation,
+		semconv17.GraphqlDocument("mutation { login }"),
+	}, spans[1].Attributes())
+}
+
+func TestRecordError(t *testing.T) {
+	exporter := tracetest.NewInMemoryExporter(t)
+
+	_, span := StartSpan(context.Background(), "error")
+	RecordError(span, errors.New("failed"), trace.WithAttributes(attribute.String("a", "b")))
+	span.End()
+	_, span = StartSpan(context.Background(), "nil error")
+	RecordError(span, nil)
+	span.End()
+	_, span = StartSpan(context.Background(), "ok")
+	SetOK(span)
+	span.End()
+
+	spans := exporter.GetSpans().Snapshots()
+	require.Len(t, spans, 3)
+
+	assert.Equal(t, sdktrace.Status{Code: codes.Error, Description: "failed"}, spans[0].Status())
+	require.Len(t, spans[0].Events(), 1)
+	assert.Equal(t, semconv.ExceptionEventName, spans[0].Events()[0].Name)
+	assert.Contains(t, spans[0].Events()[0].Attributes, attribute.String("a", "b"))
+
+	assert.Equal(t, codes.Unset, spans[1].Status().Code)
+	assert.Empty(t, spans[1].Events())
+
+	assert.Equal(t, codes.Ok, spans[2].Status().Code)
+}